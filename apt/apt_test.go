@@ -0,0 +1,132 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package apt
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/laher/argo/deb"
+)
+
+// writePackage writes a minimal package named name to path, of the given architecture and section
+// (which may be empty). It installs each of files, or just usr/bin/<name> if none are given.
+func writePackage(t *testing.T, path, name, version, arch, section string, files ...string) {
+	var buf bytes.Buffer
+	dw := deb.NewWriter(&buf)
+	dw.ModTime = time.Unix(1405990895, 0)
+	control := fmt.Sprintf("Package: %s\nVersion: %s\nArchitecture: %s\n", name, version, arch)
+	if section != "" {
+		control += "Section: " + section + "\n"
+	}
+	control += fmt.Sprintf("Maintainer: Am Laher <am@laher.net.nz>\nDescription: %s\n a test package\n", name)
+	if err := dw.WriteControlFile("control", 0644, []byte(control)); err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		files = []string{"usr/bin/" + name}
+	}
+	body := "#!/bin/sh\necho " + name + "\n"
+	for _, f := range files {
+		if err := dw.WriteHeader(&tar.Header{Name: "./" + f, Mode: 0755, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		dw.Write([]byte(body))
+	}
+	if err := dw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	root, err := ioutil.TempDir("", "apt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writePackage(t, filepath.Join(root, "pool", "main", "z", "zed_1.0_all.deb"), "zed", "1.0", "all", "")
+	writePackage(t, filepath.Join(root, "pool", "main", "a", "alpha_2.0_all.deb"), "alpha", "2.0", "all", "")
+	ioutil.WriteFile(filepath.Join(root, "pool", "README"), []byte("not a package"), 0644)
+
+	pkgs, err := ScanPackages(root, "pool")
+	if err != nil {
+		t.Fatalf("ScanPackages: %v", err)
+	}
+	if len(pkgs) != 2 {
+		t.Fatalf("found %d packages, want 2", len(pkgs))
+	}
+	alpha := pkgs[0]
+	if alpha.Filename != "pool/main/a/alpha_2.0_all.deb" {
+		t.Errorf("Filename = %q", alpha.Filename)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(root, "pool", "main", "a", "alpha_2.0_all.deb"))
+	if alpha.Size != int64(len(b)) || alpha.SHA256 != fmt.Sprintf("%x", sha256.Sum256(b)) {
+		t.Errorf("checksums = %+v, want size %d and sha256 %x", alpha.Checksums, len(b), sha256.Sum256(b))
+	}
+	var names []string
+	for _, f := range alpha.Paragraph() {
+		names = append(names, f.Name)
+	}
+	if got, want := strings.Join(names, " "), "Package Version Architecture Maintainer Filename Size MD5sum SHA1 SHA256 Description"; got != want {
+		t.Errorf("fields = %s, want %s", got, want)
+	}
+
+	fields := deb.Paragraph{{Name: "Origin", Value: "argo"}, {Name: "Date", Value: "Tue, 22 Jul 2014 01:01:35 UTC"}}
+	if err := WriteIndexes(root, pkgs, fields); err != nil {
+		t.Fatalf("WriteIndexes: %v", err)
+	}
+	packages, err := ioutil.ReadFile(filepath.Join(root, "Packages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stanzas := strings.Split(strings.TrimSuffix(string(packages), "\n\n"), "\n\n")
+	if len(stanzas) != 2 || !strings.HasPrefix(stanzas[0], "Package: alpha\n") || !strings.HasPrefix(stanzas[1], "Package: zed\n") {
+		t.Errorf("Packages =\n%s", packages)
+	}
+	gz, err := os.Open(filepath.Join(root, "Packages.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gz.Close()
+	zr, err := gzip.NewReader(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unzipped, err := ioutil.ReadAll(zr); err != nil || !bytes.Equal(unzipped, packages) {
+		t.Errorf("Packages.gz does not hold Packages: %v", err)
+	}
+
+	release, err := ioutil.ReadFile(filepath.Join(root, "Release"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := deb.ParseParagraph(bytes.NewReader(release))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if origin, _ := p.Get("Origin"); origin != "argo" {
+		t.Errorf("Origin = %q", origin)
+	}
+	sha, _ := p.Get("SHA256")
+	want := fmt.Sprintf("\n %x %16d Packages", sha256.Sum256(packages), len(packages))
+	if !strings.HasPrefix(sha, want) {
+		t.Errorf("SHA256 = %q, want it to start %q", sha, want)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package apt
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/laher/argo/deb"
+)
+
+const (
+	// ContentsPrefix is the name of a Contents index, minus its architecture and compression suffix.
+	ContentsPrefix = "Contents-"
+	// contentsColumn is the column at which a Contents line's package list starts, unless the path is longer.
+	contentsColumn = 60
+)
+
+// A ContentsCache remembers the paths installed by packages, keyed by each package's SHA256,
+// so that regenerating Contents indexes only reads packages which are new since the last run.
+type ContentsCache struct {
+	Paths map[string][]string
+	used  map[string]bool
+}
+
+// LoadContentsCache reads a cache saved by Save. A missing file gives an empty cache.
+func LoadContentsCache(file string) (*ContentsCache, error) {
+	c := &ContentsCache{Paths: make(map[string][]string)}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c.Paths); err != nil {
+		return nil, fmt.Errorf("apt: contents cache %s: %w", file, err)
+	}
+	return c, nil
+}
+
+// Save writes the cache to file, leaving out any package not looked up since the cache was loaded.
+func (c *ContentsCache) Save(file string) error {
+	keep := make(map[string][]string, len(c.used))
+	for sum := range c.used {
+		keep[sum] = c.Paths[sum]
+	}
+	b, err := json.Marshal(keep)
+	if err != nil {
+		return err
+	}
+	return writeFile(file, b)
+}
+
+// PackageContents returns the paths of the files installed by the package, without any leading "./".
+// Directories are left out. The package is read from the repository at root, unless cache already holds its paths.
+// cache may be nil.
+func PackageContents(root string, p *Package, cache *ContentsCache) ([]string, error) {
+	if cache != nil {
+		if cache.used == nil {
+			cache.used = make(map[string]bool)
+		}
+		cache.used[p.SHA256] = true
+		if paths, ok := cache.Paths[p.SHA256]; ok {
+			return paths, nil
+		}
+	}
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(p.Filename)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var paths []string
+	err = deb.WalkData(bufio.NewReader(f), func(hdr *tar.Header, _ io.Reader) error {
+		if hdr.Typeflag != tar.TypeDir {
+			paths = append(paths, strings.TrimPrefix(path.Clean("/"+hdr.Name), "/"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("apt: %s: %w", p.Filename, err)
+	}
+	if cache != nil {
+		if cache.Paths == nil {
+			cache.Paths = make(map[string][]string)
+		}
+		cache.Paths[p.SHA256] = paths
+	}
+	return paths, nil
+}
+
+// Architectures returns the distinct architectures of pkgs, sorted, other than "all".
+func Architectures(pkgs []*Package) []string {
+	seen := make(map[string]bool)
+	var archs []string
+	for _, p := range pkgs {
+		arch, _ := p.Control.Get("Architecture")
+		if arch != "" && arch != "all" && !seen[arch] {
+			seen[arch] = true
+			archs = append(archs, arch)
+		}
+	}
+	sort.Strings(archs)
+	return archs
+}
+
+// WriteContents writes a Contents index for the packages of architecture arch,
+// and those of architecture "all", to w. Each line holds a path, then a comma-separated
+// list of the packages installing it, qualified by their sections.
+func WriteContents(w io.Writer, root, arch string, pkgs []*Package, cache *ContentsCache) error {
+	owners := make(map[string][]string)
+	for _, p := range pkgs {
+		if a, _ := p.Control.Get("Architecture"); a != arch && a != "all" {
+			continue
+		}
+		paths, err := PackageContents(root, p, cache)
+		if err != nil {
+			return err
+		}
+		location, _ := p.Control.Get("Package")
+		if section, ok := p.Control.Get("Section"); ok && section != "" {
+			location = section + "/" + location
+		}
+		for _, name := range paths {
+			if o := owners[name]; len(o) == 0 || o[len(o)-1] != location {
+				owners[name] = append(o, location)
+			}
+		}
+	}
+	names := make([]string, 0, len(owners))
+	for name := range owners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		fmt.Fprintf(bw, "%-*s %s\n", contentsColumn-1, name, strings.Join(owners[name], ","))
+	}
+	return bw.Flush()
+}
+
+// WriteContentsIndexes writes a gzipped Contents index into dir for each architecture of pkgs
+// (or a Contents-all index, if every package is of architecture "all"),
+// returning the files written, for listing in the Release file by WriteIndexes.
+func WriteContentsIndexes(dir, root string, pkgs []*Package, cache *ContentsCache) ([]IndexFile, error) {
+	archs := Architectures(pkgs)
+	if len(archs) == 0 && len(pkgs) > 0 {
+		archs = []string{"all"}
+	}
+	var files []IndexFile
+	for _, arch := range archs {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if err := WriteContents(zw, root, arch, pkgs, cache); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		f, err := writeIndex(dir, ContentsPrefix+arch+".gz", buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package apt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestContents(t *testing.T) {
+	root, err := ioutil.TempDir("", "apt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writePackage(t, filepath.Join(root, "pool", "a_amd64.deb"), "a", "1.0", "amd64", "utils", "usr/bin/a", "usr/share/doc/shared")
+	writePackage(t, filepath.Join(root, "pool", "a_arm64.deb"), "a", "1.0", "arm64", "utils", "usr/bin/a")
+	writePackage(t, filepath.Join(root, "pool", "doc_all.deb"), "doc", "1.0", "all", "", "usr/share/doc/shared")
+	pkgs, err := ScanPackages(root, "pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Architectures(pkgs); !reflect.DeepEqual(got, []string{"amd64", "arm64"}) {
+		t.Errorf("Architectures = %q", got)
+	}
+
+	cache := &ContentsCache{}
+	var buf bytes.Buffer
+	if err := WriteContents(&buf, root, "amd64", pkgs, cache); err != nil {
+		t.Fatalf("WriteContents: %v", err)
+	}
+	want := fmt.Sprintf("%-59s utils/a\n%-59s utils/a,doc\n", "usr/bin/a", "usr/share/doc/shared")
+	if buf.String() != want {
+		t.Errorf("Contents-amd64 =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	files, err := WriteContentsIndexes(root, root, pkgs, cache)
+	if err != nil {
+		t.Fatalf("WriteContentsIndexes: %v", err)
+	}
+	if len(files) != 2 || files[0].Path != "Contents-amd64.gz" || files[1].Path != "Contents-arm64.gz" {
+		t.Fatalf("files = %+v", files)
+	}
+	gz, err := os.Open(filepath.Join(root, "Contents-arm64.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gz.Close()
+	zr, err := gzip.NewReader(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(zr)
+	if want := fmt.Sprintf("%-59s utils/a\n%-59s doc\n", "usr/bin/a", "usr/share/doc/shared"); string(b) != want {
+		t.Errorf("Contents-arm64.gz =\n%s\nwant\n%s", b, want)
+	}
+
+	if err := WriteIndexes(root, pkgs, nil, files...); err != nil {
+		t.Fatal(err)
+	}
+	release, _ := ioutil.ReadFile(filepath.Join(root, "Release"))
+	if !strings.Contains(string(release), " Contents-arm64.gz\n") {
+		t.Errorf("Release does not list Contents-arm64.gz:\n%s", release)
+	}
+}
+
+func TestContentsCache(t *testing.T) {
+	root, err := ioutil.TempDir("", "apt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writePackage(t, filepath.Join(root, "pool", "a.deb"), "a", "1.0", "all", "", "usr/bin/a")
+	writePackage(t, filepath.Join(root, "pool", "b.deb"), "b", "1.0", "all", "", "usr/bin/b")
+	pkgs, err := ScanPackages(root, "pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheFile := filepath.Join(root, "contents.cache")
+	cache, err := LoadContentsCache(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first bytes.Buffer
+	if err := WriteContents(&first, root, "all", pkgs, cache); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(cacheFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// with a cached package gone from disk, its contents come from the cache
+	os.Remove(filepath.Join(root, "pool", "a.deb"))
+	cache, err = LoadContentsCache(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var second bytes.Buffer
+	if err := WriteContents(&second, root, "all", pkgs, cache); err != nil {
+		t.Fatalf("WriteContents from cache: %v", err)
+	}
+	if first.String() != second.String() {
+		t.Errorf("cached contents differ:\n%s\nwant\n%s", second.String(), first.String())
+	}
+
+	// packages no longer indexed are dropped when the cache is saved
+	cache, _ = LoadContentsCache(cacheFile)
+	if err := WriteContents(ioutil.Discard, root, "all", pkgs[1:], cache); err != nil {
+		t.Fatal(err)
+	}
+	cache.Save(cacheFile)
+	cache, _ = LoadContentsCache(cacheFile)
+	if _, ok := cache.Paths[pkgs[0].SHA256]; ok || len(cache.Paths) != 1 {
+		t.Errorf("cache holds %d packages after pruning, want 1", len(cache.Paths))
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package apt generates the indexes of an APT repository from a pool of Debian packages,
+// much as dpkg-scanpackages and apt-ftparchive do.
+//
+// A flat repository, as used with a sources.list line such as
+//
+//	deb [trusted=yes] file:/srv/repo ./
+//
+// needs only a Packages index (optionally compressed) and a Release file alongside it.
+//
+// References:
+//
+//	https://wiki.debian.org/DebianRepository/Format
+package apt
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/laher/argo/deb"
+)
+
+// Checksums records the size and hashes of a file, as listed in Packages and Release files.
+type Checksums struct {
+	Size   int64
+	MD5sum string
+	SHA1   string
+	SHA256 string
+}
+
+// Sum reads r to the end, returning its size and hashes.
+func Sum(r io.Reader) (Checksums, error) {
+	s := newSummer()
+	if _, err := io.Copy(s, r); err != nil {
+		return Checksums{}, err
+	}
+	return s.checksums(), nil
+}
+
+// summer computes Checksums of the data written to it.
+type summer struct {
+	n                 int64
+	md5, sha1, sha256 hash.Hash
+}
+
+func newSummer() *summer {
+	return &summer{md5: md5.New(), sha1: sha1.New(), sha256: sha256.New()}
+}
+
+func (s *summer) Write(b []byte) (int, error) {
+	s.md5.Write(b)
+	s.sha1.Write(b)
+	s.sha256.Write(b)
+	s.n += int64(len(b))
+	return len(b), nil
+}
+
+func (s *summer) checksums() Checksums {
+	return Checksums{
+		Size:   s.n,
+		MD5sum: fmt.Sprintf("%x", s.md5.Sum(nil)),
+		SHA1:   fmt.Sprintf("%x", s.sha1.Sum(nil)),
+		SHA256: fmt.Sprintf("%x", s.sha256.Sum(nil)),
+	}
+}
+
+// A Package is a .deb file in a repository, along with its control file.
+type Package struct {
+	Control  deb.Paragraph
+	Filename string // path of the .deb, relative to the repository root and slash-separated
+	Checksums
+}
+
+// ScanPackage reads the package at rel within the repository at root,
+// parsing its control file and checksumming the whole file in a single pass.
+func ScanPackage(root, rel string) (*Package, error) {
+	f, err := os.Open(filepath.Join(root, rel))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sums := newSummer()
+	br := bufio.NewReader(io.TeeReader(f, sums))
+	control, err := deb.ReadControl(br)
+	if err == nil {
+		// checksum the rest of the package
+		_, err = io.Copy(ioutil.Discard, br)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("apt: %s: %w", rel, err)
+	}
+	return &Package{Control: control, Filename: filepath.ToSlash(rel), Checksums: sums.checksums()}, nil
+}
+
+// ScanPackages finds every .deb file below dir within the repository at root,
+// returning them sorted by package name, then by file name.
+func ScanPackages(root, dir string) ([]*Package, error) {
+	var pkgs []*Package
+	err := filepath.Walk(filepath.Join(root, dir), func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".deb") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		p, err := ScanPackage(root, rel)
+		if err != nil {
+			return err
+		}
+		pkgs = append(pkgs, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		ni, _ := pkgs[i].Control.Get("Package")
+		nj, _ := pkgs[j].Control.Get("Package")
+		if ni != nj {
+			return ni < nj
+		}
+		return pkgs[i].Filename < pkgs[j].Filename
+	})
+	return pkgs, nil
+}
+
+// Paragraph returns the package's stanza for a Packages index: its control fields,
+// with the file's name, size and hashes added before the Description, as dpkg-scanpackages places them.
+func (p *Package) Paragraph() deb.Paragraph {
+	file := deb.Paragraph{
+		{Name: "Filename", Value: p.Filename},
+		{Name: "Size", Value: strconv.FormatInt(p.Size, 10)},
+		{Name: "MD5sum", Value: p.MD5sum},
+		{Name: "SHA1", Value: p.SHA1},
+		{Name: "SHA256", Value: p.SHA256},
+	}
+	var out deb.Paragraph
+	for _, f := range p.Control {
+		if strings.EqualFold(f.Name, "Description") && file != nil {
+			out, file = append(out, file...), nil
+		}
+		out = append(out, f)
+	}
+	return append(out, file...)
+}
+
+// WritePackages writes a Packages index of pkgs to w, one stanza per package,
+// each followed by a blank line as dpkg-scanpackages writes them.
+func WritePackages(w io.Writer, pkgs []*Package) error {
+	for _, p := range pkgs {
+		if _, err := io.WriteString(w, p.Paragraph().String()+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package apt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/laher/argo/deb"
+)
+
+const (
+	// PackagesName is the name of an uncompressed Packages index.
+	PackagesName = "Packages"
+	// ReleaseName is the name of a Release file.
+	ReleaseName = "Release"
+	// releaseDate is the format of a Release file's Date field.
+	releaseDate = "Mon, 02 Jan 2006 15:04:05 UTC"
+)
+
+// An IndexFile is a file listed in a Release file, such as a Packages index.
+type IndexFile struct {
+	Path string // path relative to the Release file, slash-separated
+	Checksums
+}
+
+// WriteRelease writes a Release file to w, made up of fields (such as Origin, Suite and Architectures)
+// followed by the MD5Sum, SHA1 and SHA256 lists of files.
+func WriteRelease(w io.Writer, fields deb.Paragraph, files []IndexFile) error {
+	var b bytes.Buffer
+	b.WriteString(fields.String())
+	lists := []struct {
+		name string
+		sum  func(c Checksums) string
+	}{
+		{"MD5Sum", func(c Checksums) string { return c.MD5sum }},
+		{"SHA1", func(c Checksums) string { return c.SHA1 }},
+		{"SHA256", func(c Checksums) string { return c.SHA256 }},
+	}
+	for _, l := range lists {
+		fmt.Fprintf(&b, "%s:\n", l.name)
+		for _, f := range files {
+			fmt.Fprintf(&b, " %s %16d %s\n", l.sum(f.Checksums), f.Size, f.Path)
+		}
+	}
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// WriteIndexes writes Packages, Packages.gz and Release files for pkgs into dir,
+// which is the top of a flat repository (or a distribution's binary directory).
+// The Release file holds fields, with a Date added unless fields has one.
+// It lists the Packages indexes, along with any extra files already written, such as Contents indexes.
+func WriteIndexes(dir string, pkgs []*Package, fields deb.Paragraph, extra ...IndexFile) error {
+	var packages bytes.Buffer
+	if err := WritePackages(&packages, pkgs); err != nil {
+		return err
+	}
+	var packagesGz bytes.Buffer
+	zw := gzip.NewWriter(&packagesGz)
+	if _, err := zw.Write(packages.Bytes()); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	var files []IndexFile
+	for _, index := range []struct {
+		name string
+		data []byte
+	}{
+		{PackagesName, packages.Bytes()},
+		{PackagesName + ".gz", packagesGz.Bytes()},
+	} {
+		f, err := writeIndex(dir, index.name, index.data)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+	files = append(files, extra...)
+
+	if _, ok := fields.Get("Date"); !ok {
+		fields = append(fields[:len(fields):len(fields)], deb.ControlField{Name: "Date", Value: time.Now().UTC().Format(releaseDate)})
+	}
+	var release bytes.Buffer
+	if err := WriteRelease(&release, fields, files); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, ReleaseName), release.Bytes())
+}
+
+// writeIndex writes an index file named name into dir, returning its checksums.
+func writeIndex(dir, name string, data []byte) (IndexFile, error) {
+	if err := writeFile(filepath.Join(dir, name), data); err != nil {
+		return IndexFile{}, err
+	}
+	c, err := Sum(bytes.NewReader(data))
+	return IndexFile{name, c}, err
+}
+
+// writeFile replaces the file at path, via a temporary file, so that clients never see a partial index.
+func writeFile(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
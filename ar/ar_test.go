@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -39,8 +40,8 @@ func TestFileInfoHeader(t *testing.T) {
 	if g, e := h.Name, "small.txt"; g != e {
 		t.Errorf("Name = %q; want %q", g, e)
 	}
-	if g, e := h.Mode, int64(fi.Mode().Perm()); g != e {
-		t.Errorf("Mode = %#o; want %#o", g, e)
+	if g, e := strconv.FormatInt(h.Mode, 10), strconv.FormatUint(uint64(0100000|fi.Mode().Perm()), 8); g != e {
+		t.Errorf("Mode = %s; want %s", g, e)
 	}
 	if g, e := h.Size, int64(5); g != e {
 		t.Errorf("Size = %v; want %v", g, e)
@@ -103,7 +104,7 @@ func TestHeaderRoundTrip(t *testing.T) {
 		{
 			h: &Header{
 				Name:    "test.txt",
-				Mode:    0644,
+				Mode:    100644,
 				Size:    12,
 				ModTime: time.Unix(1360600916, 0),
 				//	Typeflag: TypeReg,
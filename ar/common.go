@@ -8,7 +8,9 @@
 
 // Package ar implements access to ar archives.
 // argo only implements the 'common' format as used for .deb files, by GNU ar, and by BSD ar. AIX and Coherent variants are not supported.
-// Note that argo is not currently supporting the workarounds for long filenames as defined by GNU ar or BSD ar. Please get in touch if you require this feature.
+// The Reader resolves BSD long filenames ("#1/N"), and classifies special members, including GNU and BSD symbol tables
+// and the GNU long filename table ("//"), by Header.Kind. GNU "/N" long name references are left as they are, though Verify checks them.
+// The Writer does not produce long filenames.
 //
 // References:
 //   http://en.wikipedia.org/wiki/Ar_(Unix)
@@ -20,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -42,6 +45,10 @@ const (
 	sizeSize = 10
 	// the length of the 'magic' number
 	magicSize = 2
+	// the string used to identify a GNU thin archive
+	thinFileHeader = "!<thin>\n"
+	// the maximum length accepted for a BSD long filename
+	maxBSDNameSize = 4096
 )
 
 var (
@@ -72,8 +79,70 @@ type Header struct {
 	ModTime time.Time // modified time
 	Uid     int       // user id of owner
 	Gid     int       // group id of owner
-	Mode    int64     // permission and mode bits
-	Size    int64     // length in bytes
+	// Mode is the mode as written in the header: its octal digits, read as a decimal number.
+	// A regular file with permissions rw-r--r-- is 100644, or 644 for the permissions alone.
+	// It is not the permission bits, so a Go octal literal 0644 (420) is written as 100420.
+	// FileInfo and FileInfoHeader convert to and from os.FileMode.
+	Mode int64
+	Size int64 // length in bytes
+	Kind Kind  // kind of member, as determined by a Reader. Ignored by a Writer.
+}
+
+// Kind distinguishes the special members which ar tools add to an archive from ordinary data members.
+type Kind int
+
+const (
+	// KindRegular is an ordinary data member
+	KindRegular Kind = iota
+	// KindSymbolTable is a GNU or System V symbol table, named "/"
+	KindSymbolTable
+	// KindSymbolTable64 is a GNU symbol table with 64-bit offsets, named "/SYM64/"
+	KindSymbolTable64
+	// KindLongNameTable is a GNU long filename table, named "//"
+	KindLongNameTable
+	// KindBSDSymdef is a BSD symbol table, named "__.SYMDEF" or "__.SYMDEF SORTED"
+	KindBSDSymdef
+	// KindCOFFLinkerMember is the second linker member of a Windows COFF archive, also named "/"
+	KindCOFFLinkerMember
+	// KindThinReference is a member of a GNU thin archive, whose data is held in an external file named by the member
+	KindThinReference
+)
+
+var kindNames = []string{
+	KindRegular:          "regular",
+	KindSymbolTable:      "symbol table",
+	KindSymbolTable64:    "64-bit symbol table",
+	KindLongNameTable:    "long name table",
+	KindBSDSymdef:        "BSD symdef",
+	KindCOFFLinkerMember: "COFF linker member",
+	KindThinReference:    "thin reference",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "unknown"
+	}
+	return kindNames[k]
+}
+
+// IsSpecial reports whether the kind is one of ar's own metadata members, rather than a data member.
+func (k Kind) IsSpecial() bool {
+	return k != KindRegular && k != KindThinReference
+}
+
+// kindOf classifies a member by the name field written in its header.
+func kindOf(rawName string) Kind {
+	switch {
+	case rawName == "/":
+		return KindSymbolTable
+	case rawName == "/SYM64/":
+		return KindSymbolTable64
+	case rawName == "//":
+		return KindLongNameTable
+	case isBSDSymdef(rawName):
+		return KindBSDSymdef
+	}
+	return KindRegular
 }
 
 type slicer []byte
@@ -103,7 +172,11 @@ func (fi headerFileInfo) Name() string       { return fi.h.Name }
 // Mode returns the permission and mode bits for the headerFileInfo.
 func (fi headerFileInfo) Mode() (mode os.FileMode) {
 	// Set file permission bits.
-	mode = os.FileMode(fi.h.Mode).Perm()
+	bits, err := strconv.ParseUint(strconv.FormatInt(fi.h.Mode, 10), 8, 32)
+	if err != nil {
+		return 0
+	}
+	mode = os.FileMode(bits).Perm()
 	return mode
 }
 
@@ -123,7 +196,8 @@ func FileInfoHeader(fi os.FileInfo) (*Header, error) {
 	}
 	fm := fi.Mode()
 	h.ModTime = fi.ModTime()
-	h.Mode = int64(fm.Perm())
+	// the mode field holds octal digits, so 0755 is written as 100755
+	h.Mode, _ = strconv.ParseInt(strconv.FormatUint(uint64(0100000|fm.Perm()), 8), 10, 64)
 
 	return h, nil
 }
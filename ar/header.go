@@ -0,0 +1,175 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format selects a variant of the ar header encoding.
+type Format int
+
+const (
+	// FormatCommon terminates names with spaces alone, as .deb files do.
+	FormatCommon Format = iota
+	// FormatGNU terminates names with a slash, as GNU ar does.
+	FormatGNU
+)
+
+// A FieldError reports a Header field which cannot be encoded in an ar header.
+type FieldError struct {
+	Field string // name of the field, e.g. "size"
+	Value string // the value which could not be encoded
+	Width int    // width of the field in bytes
+	Err   error  // ErrFieldTooLong, ErrFieldNegative or ErrFieldNotOctal
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%v: %s %s (field is %d bytes)", e.Err, e.Field, e.Value, e.Width)
+}
+
+// Unwrap returns the underlying ErrFieldTooLong, ErrFieldNegative or ErrFieldNotOctal.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Encode encodes h as a 60-byte ar header in the given format.
+// Every field is checked against its width, and a *FieldError is returned for any which does not fit.
+func (h *Header) Encode(format Format) ([]byte, error) {
+	return encodeHeader(h, format == FormatGNU)
+}
+
+// MarshalBinary encodes h as a 60-byte ar header, in FormatCommon.
+// It implements encoding.BinaryMarshaler.
+func (h *Header) MarshalBinary() ([]byte, error) {
+	return h.Encode(FormatCommon)
+}
+
+// UnmarshalBinary decodes a 60-byte ar header into h, in either format.
+// It implements encoding.BinaryUnmarshaler.
+func (h *Header) UnmarshalBinary(b []byte) error {
+	if len(b) != headerSize {
+		return ErrHeader
+	}
+	hdr, _, err := parseHeader(b)
+	if err != nil {
+		return err
+	}
+	*h = *hdr
+	return nil
+}
+
+// parseHeader decodes a 60-byte ar header.
+// rawName is the name field as written, including any terminating slash,
+// which distinguishes the special members.
+func parseHeader(header []byte) (hdr *Header, rawName string, err error) {
+	hdr = new(Header)
+	s := slicer(header)
+
+	rawName = strings.TrimSpace(string(s.next(fileNameSize)))
+	hdr.Name = rawName
+	if strings.HasSuffix(hdr.Name, "/") {
+		hdr.Name = hdr.Name[:len(hdr.Name)-1]
+	}
+	hdr.Kind = kindOf(rawName)
+	modTime, err := parseBlankInt(s.next(modTimeSize))
+	if err != nil {
+		return nil, rawName, err
+	}
+	hdr.ModTime = time.Unix(modTime, int64(0))
+	uid, err := parseBlankInt(s.next(uidSize))
+	if err != nil {
+		return nil, rawName, err
+	}
+	gid, err := parseBlankInt(s.next(gidSize))
+	if err != nil {
+		return nil, rawName, err
+	}
+	hdr.Uid, hdr.Gid = int(uid), int(gid)
+	if hdr.Mode, err = parseBlankInt(s.next(modeSize)); err != nil {
+		return nil, rawName, err
+	}
+	if hdr.Size, err = strconv.ParseInt(strings.TrimSpace(string(s.next(sizeSize))), 10, 64); err != nil {
+		return nil, rawName, err
+	}
+	magic := s.next(magicSize)
+	if magic[0] != 0x60 || magic[1] != 0x0a {
+		return nil, rawName, ErrHeader
+	}
+	return hdr, rawName, nil
+}
+
+// parseBlankInt parses a decimal header field, treating a blank field as zero.
+// GNU ar leaves the fields of its long filename table blank.
+func parseBlankInt(field []byte) (int64, error) {
+	s := strings.TrimSpace(string(field))
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// encodeHeader encodes hdr as a 60-byte ar header, checking that every field fits its width.
+// Names are terminated with a slash if slash is set, as GNU ar does.
+// A zero ModTime is encoded as 0. A Mode holding only permission digits (e.g. 644)
+// is encoded as a regular file (100644). A Mode with a digit 8 or 9 is not octal, and is refused.
+func encodeHeader(hdr *Header, slash bool) ([]byte, error) {
+	name := hdr.Name
+	if slash {
+		name += "/"
+	}
+	if len(name) > fileNameSize {
+		return nil, &FieldError{"name", name, fileNameSize, ErrFieldTooLong}
+	}
+	var modTime int64
+	if !hdr.ModTime.IsZero() {
+		modTime = hdr.ModTime.Unix()
+	}
+	mode := hdr.Mode
+	if mode >= 0 && mode <= 7777 {
+		//Files only atm (not dirs)
+		mode += 100000
+	}
+	fields := []struct {
+		label string
+		value int64
+		width int
+		octal bool // whether the field holds octal digits
+	}{
+		{"modification time", modTime, modTimeSize, false},
+		{"uid", int64(hdr.Uid), uidSize, false},
+		{"gid", int64(hdr.Gid), gidSize, false},
+		{"mode", mode, modeSize, true},
+		{"size", hdr.Size, sizeSize, false},
+	}
+	b := make([]byte, 0, headerSize)
+	b = append(b, pad(name, fileNameSize)...)
+	for _, f := range fields {
+		s, err := formatField(f.label, f.value, f.width)
+		if err != nil {
+			return nil, err
+		}
+		if f.octal && strings.ContainsAny(s, "89") {
+			return nil, &FieldError{f.label, s, f.width, ErrFieldNotOctal}
+		}
+		b = append(b, pad(s, f.width)...)
+	}
+	return append(b, "`\n"...), nil
+}
+
+// formatField formats a numeric header field, checking it fits in width bytes.
+func formatField(label string, value int64, width int) (string, error) {
+	s := strconv.FormatInt(value, 10)
+	if value < 0 {
+		return "", &FieldError{label, s, width, ErrFieldNegative}
+	}
+	if len(s) > width {
+		return "", &FieldError{label, s, width, ErrFieldTooLong}
+	}
+	return s, nil
+}
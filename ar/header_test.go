@@ -0,0 +1,72 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ar
+
+import (
+	"encoding"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*Header)(nil)
+	_ encoding.BinaryUnmarshaler = (*Header)(nil)
+)
+
+func TestHeaderMarshalBinary(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/common.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := b[arHeaderSize : arHeaderSize+headerSize]
+	var h Header
+	if err := h.UnmarshalBinary(raw); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if want := *simpleArTest.headers[0]; !reflect.DeepEqual(h, want) {
+		t.Errorf("UnmarshalBinary = %+v, want %+v", h, want)
+	}
+
+	// patch the modification time in place
+	h.ModTime = time.Unix(1500000000, 0)
+	patched, err := h.Encode(FormatGNU)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if want := string(raw[:16]) + "1500000000  " + string(raw[28:]); string(patched) != want {
+		t.Errorf("Encode(FormatGNU) = %q, want %q", patched, want)
+	}
+
+	common, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	if want := "small.txt       "; string(common[:16]) != want {
+		t.Errorf("MarshalBinary name field = %q, want %q", common[:16], want)
+	}
+	var h2 Header
+	if err := h2.UnmarshalBinary(common); err != nil || !reflect.DeepEqual(h, h2) {
+		t.Errorf("UnmarshalBinary(MarshalBinary) = %+v, %v; want %+v", h2, err, h)
+	}
+}
+
+func TestHeaderUnmarshalBinaryErrors(t *testing.T) {
+	good, err := (&Header{Name: "a.txt", Size: 1}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	badMagic := append([]byte(nil), good...)
+	badMagic[headerSize-1] = 'x'
+	badSize := append([]byte(nil), good...)
+	copy(badSize[48:], "1x")
+	for i, b := range [][]byte{good[:59], badMagic, badSize} {
+		var h Header
+		if err := h.UnmarshalBinary(b); err == nil {
+			t.Errorf("test %d: UnmarshalBinary(%q) should fail", i, b)
+		}
+	}
+}
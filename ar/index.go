@@ -0,0 +1,80 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ar
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotFound shows that no member matched a lookup
+var ErrNotFound = errors.New("ar: member not found")
+
+// An IndexEntry locates a single member of an indexed archive.
+type IndexEntry struct {
+	Header
+	HeaderOffset int64 // offset of the member's header
+	DataOffset   int64 // offset of the member's data
+}
+
+// An Index provides random access to the members of an archive.
+// ar permits several members with the same name; these are distinguished by
+// their instance number, counting from 1 in archive order as with GNU ar's 'N count' modifier.
+type Index struct {
+	r       io.ReaderAt
+	Entries []*IndexEntry // members in archive order
+}
+
+// NewIndex reads the headers of the archive in r, which is size bytes long.
+func NewIndex(r io.ReaderAt, size int64) (*Index, error) {
+	arr, err := NewReader(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	idx := &Index{r: r}
+	for {
+		hdr, err := arr.Next()
+		if err == io.EOF {
+			return idx, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		idx.Entries = append(idx.Entries, &IndexEntry{*hdr, arr.HeaderOffset(), arr.DataOffset()})
+	}
+}
+
+// Instances returns every member named name, in archive order.
+func (idx *Index) Instances(name string) []*IndexEntry {
+	var found []*IndexEntry
+	for _, e := range idx.Entries {
+		if e.Name == name {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Lookup returns the nth instance of name, counting from 1.
+func (idx *Index) Lookup(name string, n int) (*IndexEntry, error) {
+	if n > 0 {
+		seen := 0
+		for _, e := range idx.Entries {
+			if e.Name != name {
+				continue
+			}
+			if seen++; seen == n {
+				return e, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %q (instance %d)", ErrNotFound, name, n)
+}
+
+// Open returns a reader of the data of an entry.
+func (idx *Index) Open(e *IndexEntry) *io.SectionReader {
+	return io.NewSectionReader(idx.r, e.DataOffset, e.Size)
+}
@@ -0,0 +1,116 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ar
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+// writeMembers writes an archive of (name, contents) pairs with the given duplicate policy.
+func writeMembers(t *testing.T, policy DuplicatePolicy, members ...string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	tw := NewWriter(buf)
+	tw.Duplicates = policy
+	for i := 0; i < len(members); i += 2 {
+		if err := tw.WriteHeader(&Header{Name: members[i], Size: int64(len(members[i+1]))}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write([]byte(members[i+1])); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func TestIndex(t *testing.T) {
+	b, err := writeMembers(t, AllowDuplicates, "util.o", "first", "main.o", "main", "util.o", "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := NewIndex(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("NewIndex: %v", err)
+	}
+	if len(idx.Entries) != 3 {
+		t.Fatalf("Got %d entries, want 3", len(idx.Entries))
+	}
+	if instances := idx.Instances("util.o"); len(instances) != 2 || instances[0].HeaderOffset >= instances[1].HeaderOffset {
+		t.Errorf("Unexpected instances of util.o: %v", instances)
+	}
+	for n, want := range []string{"first", "second"} {
+		e, err := idx.Lookup("util.o", n+1)
+		if err != nil {
+			t.Fatalf("Lookup(util.o, %d): %v", n+1, err)
+		}
+		got, err := ioutil.ReadAll(idx.Open(e))
+		if err != nil || string(got) != want {
+			t.Errorf("Lookup(util.o, %d) contents = %q, %v; want %q", n+1, got, err, want)
+		}
+	}
+	for _, n := range []int{0, 3} {
+		if _, err := idx.Lookup("util.o", n); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Lookup(util.o, %d) error = %v, want %v", n, err, ErrNotFound)
+		}
+	}
+}
+
+func TestDuplicatePolicies(t *testing.T) {
+	if _, err := writeMembers(t, RefuseDuplicates, "util.o", "first", "util.o", "second"); !errors.Is(err, ErrDuplicateName) {
+		t.Errorf("RefuseDuplicates error = %v, want %v", err, ErrDuplicateName)
+	}
+
+	replaced, err := writeMembers(t, ReplaceDuplicates, "util.o", "first", "main.o", "main", "util.o", "replacement")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := writeMembers(t, AllowDuplicates, "util.o", "replacement", "main.o", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(replaced, want) {
+		t.Errorf("ReplaceDuplicates: (-=expected, +=actual)\n%v", bytediff(want, replaced))
+	}
+
+	// a header which fails to encode must not register its name, nor replace the earlier member
+	for _, policy := range []DuplicatePolicy{RefuseDuplicates, ReplaceDuplicates} {
+		buf := new(bytes.Buffer)
+		tw := NewWriter(buf)
+		tw.Duplicates = policy
+		if err := tw.WriteHeader(&Header{Name: "util.o", Size: 5}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte("first")); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.WriteHeader(&Header{Name: "main.o", Uid: -1}); !errors.Is(err, ErrFieldNegative) {
+			t.Errorf("policy %d: bad header error = %v, want %v", policy, err, ErrFieldNegative)
+		}
+		if err := tw.WriteHeader(&Header{Name: "util.o", Uid: -1}); !errors.Is(err, ErrFieldNegative) {
+			t.Errorf("policy %d: bad duplicate header error = %v, want %v", policy, err, ErrFieldNegative)
+		}
+		if err := tw.WriteHeader(&Header{Name: "main.o", Size: 4}); err != nil {
+			t.Errorf("policy %d: main.o after a bad header: %v", policy, err)
+		}
+		if _, err := tw.Write([]byte("main")); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		want, err := writeMembers(t, AllowDuplicates, "util.o", "first", "main.o", "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("policy %d: (-=expected, +=actual)\n%v", policy, bytediff(want, buf.Bytes()))
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package ar
+
+import (
+	"io"
+	"iter"
+)
+
+// All returns an iterator over the remaining members of the archive,
+// yielding each member's header along with a reader of its data.
+// The reader is only valid until the next iteration.
+// Iteration stops at the end of the archive or at the first error, which Err then reports.
+//
+//	for hdr, data := range arr.All() {
+//		...
+//	}
+//	if err := arr.Err(); err != nil {
+//		...
+//	}
+func (ar *Reader) All() iter.Seq2[*Header, io.Reader] {
+	return func(yield func(*Header, io.Reader) bool) {
+		ar.iterErr = ar.Walk(func(hdr *Header, r io.Reader) error {
+			if !yield(hdr, r) {
+				return errStopIteration
+			}
+			return nil
+		})
+		if ar.iterErr == errStopIteration {
+			ar.iterErr = nil
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.23
+
+package ar
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	archive := ArFileHeader + rawHeader("a", "1") + "a\n" + rawHeader("b", "2") + "bb" + rawHeader("c", "1") + "c\n"
+	tr, err := NewReader(strings.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for hdr, r := range tr.All() {
+		body, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, hdr.Name+"="+string(body))
+	}
+	if err := tr.Err(); err != nil {
+		t.Errorf("Err = %v", err)
+	}
+	if want := "a=a b=bb c=c"; strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, " "), want)
+	}
+
+	// breaking out early is not an error, and iteration can resume
+	tr, _ = NewReader(strings.NewReader(archive))
+	for range tr.All() {
+		break
+	}
+	if err := tr.Err(); err != nil {
+		t.Errorf("Err after break = %v", err)
+	}
+	n := 0
+	for range tr.All() {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("resumed iteration yielded %d members, want 2", n)
+	}
+
+	tr, _ = NewReader(strings.NewReader(archive[:len(archive)-30]))
+	n = 0
+	for range tr.All() {
+		n++
+	}
+	if err := tr.Err(); err != io.ErrUnexpectedEOF || n != 2 {
+		t.Errorf("truncated archive: %d members, Err = %v; want 2, %v", n, err, io.ErrUnexpectedEOF)
+	}
+}
@@ -10,6 +10,7 @@ package ar
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
@@ -17,7 +18,6 @@ import (
 	"os"
 	"strconv"
 	"strings"
-	"time"
 )
 
 // A Reader provides sequential access to the contents of an ar archive.
@@ -25,47 +25,145 @@ import (
 // The Next method advances to the next file in the archive (including the first),
 // and then it can be treated as an io.Reader to access the file's data.
 type Reader struct {
-	r   io.Reader
-	err error
-	nb  int64 // number of unread bytes for current file entry
-	pad bool  // whether the file will be padded an extra byte (i.e. if ther's an odd number of bytes in the file)
+	r       io.Reader
+	err     error
+	nb      int64 // number of unread bytes for current file entry
+	pad     bool  // whether the file will be padded an extra byte (i.e. if ther's an odd number of bytes in the file)
+	opts    ReaderOptions
+	members int     // number of headers read so far
+	hdr     *Header // current file entry
+	raw     []byte  // header of the current file entry, as read
+	bsdName []byte  // BSD long name of the current file entry, as read from the start of its data
+	pos     int64   // number of bytes consumed from r, i.e. the current offset within the archive
+	hdrPos  int64   // offset of the current file entry's header
+	dataPos int64   // offset of the current file entry's data
+	thin    bool    // whether this is a GNU thin archive
+	linkers int     // number of "/" members read so far
+	iterErr error   // error which ended the last iteration by All
+	padDue  bool    // whether the previous entry was followed by a padding byte
+	padByte []byte  // the previous entry's padding byte, as read
+	padRead bool    // whether padByte has already been read, by copyPadding
+}
+
+// ReaderOptions sets limits on what a Reader accepts, for reading untrusted archives.
+// A zero value for any limit means that it is not enforced.
+// Members with a negative size are always rejected, with ErrNegativeSize.
+type ReaderOptions struct {
+	MaxMemberSize      int64 // maximum Size of any member
+	MaxMembers         int   // maximum number of members
+	MaxStringTableSize int64 // maximum size of a GNU long filename table ("//")
+	MaxSymbolTableSize int64 // maximum size of a symbol table ("/", "/SYM64/" or "__.SYMDEF")
+	SkipSpecial        bool  // skip symbol tables, long name tables and other special members, returning data members only
+
+	// Strict makes the Reader reject padding other than a single "\n" after an odd-sized member, with ErrPadding.
+	// Otherwise, any padding byte is accepted (some writers use NUL), as is padding omitted before the next header or at the end of the archive.
+	Strict bool
+	// Recover makes the Reader scan forward past a damaged member header to the next plausible one,
+	// rather than failing with ErrHeader. A missing or extra padding byte is tolerated likewise.
+	// A recovering Reader does not seek, even if the underlying reader can.
+	Recover bool
+	// OnSkip, if set, is called while recovering for each region of the archive skipped.
+	OnSkip func(SkippedRegion)
+}
+
+var (
+	// ErrNegativeSize describes a member header with a negative size
+	ErrNegativeSize = errors.New("ar: negative member size")
+	// ErrPadding describes an odd-sized member which is not followed by a valid padding byte
+	ErrPadding       = errors.New("ar: invalid padding")
+	errStopIteration = errors.New("ar: iteration stopped")
+)
+
+// A LimitError reports that an archive exceeded one of the limits in ReaderOptions.
+type LimitError struct {
+	Limit string // description of the limit exceeded, e.g. "member size"
+	Name  string // name of the offending member
+	Value int64  // value found in the archive
+	Max   int64  // configured limit
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("ar: %s %d of %q exceeds limit %d", e.Limit, e.Value, e.Name, e.Max)
 }
 
 // NewReader creates a new Reader reading from r.
 // NewReader automatically reads in the ar file header, and checks it is valid.
 func NewReader(r io.Reader) (*Reader, error) {
+	return NewReaderOptions(r, nil)
+}
+
+// NewReaderOptions creates a new Reader reading from r, enforcing the limits in opts.
+// A nil opts enforces no limits.
+func NewReaderOptions(r io.Reader, opts *ReaderOptions) (*Reader, error) {
 	ar := &Reader{r: r}
+	if opts != nil {
+		ar.opts = *opts
+	}
+	if ar.opts.Recover {
+		ar.r = &pushbackReader{r: r}
+	}
 	arHeader := make([]byte, arHeaderSize)
-	_, err := io.ReadFull(ar.r, arHeader)
+	n, err := io.ReadFull(ar.r, arHeader)
+	ar.pos += int64(n)
 	if err != nil {
 		return nil, err
 	}
-	if string(arHeader) != ArFileHeader {
+	switch string(arHeader) {
+	case ArFileHeader:
+	case thinFileHeader:
+		ar.thin = true
+	default:
 		return nil, errors.New("ar: Invalid ar file")
 	}
 	return ar, nil
 }
 
-// skipUnread skips any unread bytes in the existing file entry, as well as any alignment padding.
+// skipUnread skips any unread bytes in the existing file entry.
+// If the entry is padded, the padding byte is read into padByte, for readHeader to check.
 func (ar *Reader) skipUnread() {
-	nr := ar.nb // number of bytes to skip
-	if ar.pad {
-		nr += int64(1)
-		ar.pad = false
+	if ar.padRead {
+		ar.padRead = false
+		return
 	}
+	nr := ar.nb // number of bytes to skip
+	ar.padDue, ar.pad = ar.pad, false
+	ar.padByte = nil
 	ar.nb = 0
-	if sr, ok := ar.r.(io.Seeker); ok {
+	if sr, ok := ar.r.(io.Seeker); ok && !ar.opts.Recover {
 		if _, err := sr.Seek(nr, os.SEEK_CUR); err == nil {
-			return
+			ar.pos += nr
+			nr = 0
 		}
 	}
-
-	_, ar.err = io.CopyN(ioutil.Discard, ar.r, nr)
+	n, err := io.CopyN(ioutil.Discard, ar.r, nr)
+	ar.pos += n
+	if ar.err = err; err != nil || !ar.padDue {
+		return
+	}
+	ar.padByte = make([]byte, 1)
+	m, err := io.ReadFull(ar.r, ar.padByte)
+	ar.padByte = ar.padByte[:m]
+	ar.pos += int64(m)
+	if err != io.EOF {
+		ar.err = err
+	}
 }
 
 // Next advances to the next entry in the ar archive.
+// The entry's Kind distinguishes special members, such as symbol tables, from data members.
+// If ReaderOptions.SkipSpecial is set, special members are skipped.
 func (ar *Reader) Next() (*Header, error) {
+	for {
+		hdr, err := ar.next()
+		if hdr == nil || !ar.opts.SkipSpecial || !hdr.Kind.IsSpecial() {
+			return hdr, err
+		}
+	}
+}
+
+func (ar *Reader) next() (*Header, error) {
 	var hdr *Header
+	ar.hdr, ar.raw = nil, nil
 	if ar.err == nil {
 		ar.skipUnread()
 	}
@@ -79,12 +177,19 @@ func (ar *Reader) Next() (*Header, error) {
 	return hdr, ar.err
 }
 
+// Err returns the error, if any, which ended the last iteration by All.
+// Reaching the end of the archive is not an error.
+func (ar *Reader) Err() error {
+	return ar.iterErr
+}
+
 // NextString reads a string up to a given max length.
 // This is useful for reading the first part of .a files.
 func (ar *Reader) NextString(max int) (string, error) {
 	firstLine := make([]byte, max)
 	n, err := io.ReadFull(ar.r, firstLine)
 	ar.nb -= int64(n)
+	ar.pos += int64(n)
 	if err != nil {
 		ar.err = err
 		return "", err
@@ -93,64 +198,60 @@ func (ar *Reader) NextString(max int) (string, error) {
 }
 
 func (ar *Reader) readHeader() *Header {
-	header := make([]byte, headerSize)
-	if _, ar.err = io.ReadFull(ar.r, header); ar.err != nil {
-		return nil
+	header, err := ar.readHeaderBytes()
+	var hdr *Header
+	var rawName string
+	if err == nil {
+		hdr, rawName, err = parseHeader(header)
 	}
-
-	//TODO check end of archive
-
-	// Unpack
-	hdr := new(Header)
-	s := slicer(header)
-
-	hdr.Name = strings.TrimSpace(string(s.next(fileNameSize)))
-	if strings.HasSuffix(hdr.Name, "/") {
-		hdr.Name = hdr.Name[:len(hdr.Name)-1]
+	if err != nil && ar.opts.Recover && err != io.EOF {
+		// scan from the padding byte, in case it was really the start of this header
+		win := append(append([]byte(nil), ar.padByte...), header...)
+		if header, err = ar.resync(win, ar.hdrPos-int64(len(ar.padByte)), err); err == nil {
+			hdr, rawName, err = parseHeader(header)
+		}
 	}
-	modTime, err := strconv.Atoi(strings.TrimSpace(string(s.next(modTimeSize))))
 	if err != nil {
-		log.Printf("Error: (%+v)", ar.err)
-		log.Printf(" (Header: %+v)", hdr)
-		return nil
-	}
-	hdr.ModTime = time.Unix(int64(modTime), int64(0))
-	hdr.Uid, ar.err = strconv.Atoi(strings.TrimSpace(string(s.next(uidSize))))
-	if ar.err != nil {
-		log.Printf("Error: (%+v)", ar.err)
-		log.Printf(" (Header: %+v)", hdr)
+		ar.err = err
+		if err != io.EOF && !ar.opts.Recover {
+			log.Printf("Error: (%+v)", ar.err)
+			log.Printf(" (Header: %q)", header)
+		}
 		return nil
 	}
-	hdr.Gid, err = strconv.Atoi(strings.TrimSpace(string(s.next(gidSize))))
-	if ar.err != nil {
-		log.Printf("Error: (%+v)", ar.err)
-		log.Printf(" (Header: %+v)", hdr)
+
+	if hdr.Size < 0 {
+		ar.err = ErrNegativeSize
 		return nil
 	}
-	modeStr := strings.TrimSpace(string(s.next(modeSize)))
-	hdr.Mode, ar.err = strconv.ParseInt(modeStr, 10, 64)
-	sizeStr := strings.TrimSpace(string(s.next(sizeSize)))
-	hdr.Size, ar.err = strconv.ParseInt(sizeStr, 10, 64)
-	if ar.err != nil {
-		log.Printf("Error: (%+v)", ar.err)
-		log.Printf(" (Header: %+v)", hdr)
-		return nil
+	dataSize := hdr.Size // length of the data section, including any BSD long name
+	ar.bsdName = nil
+	if strings.HasPrefix(rawName, "#1/") {
+		if ar.err = ar.readBSDName(rawName, hdr); ar.err != nil {
+			return nil
+		}
 	}
-	magic := s.next(2) // magic
-	if magic[0] != 0x60 || magic[1] != 0x0a {
-		log.Printf("Invalid magic Header (%x,%x)", int(magic[0]), int(magic[1]))
-		log.Printf(" (Header: %+v)", hdr)
-		ar.err = ErrHeader
-		return nil
+	switch {
+	case hdr.Kind == KindSymbolTable:
+		if ar.linkers++; ar.linkers > 1 {
+			hdr.Kind = KindCOFFLinkerMember
+		}
+	case ar.thin && hdr.Kind == KindRegular:
+		hdr.Kind = KindThinReference
 	}
-	if ar.err != nil {
-		log.Printf("Error: (%+v)", ar.err)
-		log.Printf(" (Header: %+v)", hdr)
+
+	if ar.err = ar.checkLimits(hdr); ar.err != nil {
 		return nil
 	}
 
 	ar.nb = hdr.Size
-	if math.Mod(float64(hdr.Size), float64(2)) == float64(1) {
+	ar.hdr, ar.raw = hdr, header
+	ar.dataPos = ar.pos
+	if hdr.Kind == KindThinReference {
+		// the data is held in an external file
+		ar.nb, dataSize = 0, 0
+	}
+	if math.Mod(float64(dataSize), float64(2)) == float64(1) {
 		ar.pad = true
 	} else {
 		ar.pad = false
@@ -158,6 +259,151 @@ func (ar *Reader) readHeader() *Header {
 	return hdr
 }
 
+// copyPadding reads the current entry's padding byte, once all its data has been read, for Writer.CopyFrom.
+// It returns the padding as it appears in the archive: nil if the entry needs none or it was omitted.
+// The padding is still checked by the next call to Next, as usual.
+func (ar *Reader) copyPadding() []byte {
+	if !ar.pad || ar.err != nil {
+		return nil
+	}
+	ar.skipUnread()
+	ar.padRead = true
+	if ar.err != nil || len(ar.padByte) == 0 || ar.padByte[0] == '\n' || ar.padByte[0] == 0 {
+		return ar.padByte
+	}
+	// perhaps the padding was omitted, and this is the first byte of the next header
+	rest := make([]byte, headerSize-1)
+	n, _ := io.ReadFull(ar.r, rest)
+	pr, ok := ar.r.(*pushbackReader)
+	if !ok {
+		pr = &pushbackReader{r: ar.r}
+		ar.r = pr
+	}
+	pr.unread(rest[:n])
+	if n == len(rest) && plausibleHeader(append(ar.padByte[:1:1], rest...)) {
+		return nil
+	}
+	return ar.padByte
+}
+
+// readHeaderBytes reads the next member header, after checking the previous member's padding byte.
+// Unless ReaderOptions.Strict is set, any padding byte is accepted,
+// as is padding omitted before a valid header or at the end of the archive.
+// It returns the bytes read, along with any error.
+func (ar *Reader) readHeaderBytes() ([]byte, error) {
+	header := make([]byte, headerSize)
+	ar.hdrPos = ar.pos
+	var padErr error
+	lookahead := false
+	switch {
+	case !ar.padDue, len(ar.padByte) == 1 && ar.padByte[0] == '\n':
+	case len(ar.padByte) == 0:
+		padErr = fmt.Errorf("%w: padding byte missing at end of archive", ErrPadding)
+	case ar.padByte[0] == 0:
+		padErr = fmt.Errorf("%w: NUL padding byte", ErrPadding)
+	default:
+		// perhaps the padding was omitted, and this is the first byte of the header
+		lookahead = true
+		header[0] = ar.padByte[0]
+	}
+	if lookahead {
+		pad := ar.padByte
+		ar.padByte = nil
+		ar.hdrPos--
+		n, err := io.ReadFull(ar.r, header[1:])
+		ar.pos += int64(n)
+		switch {
+		case err == nil && plausibleHeader(header):
+			if ar.opts.Strict {
+				return header, fmt.Errorf("%w: padding byte missing", ErrPadding)
+			}
+			return header, nil
+		case ar.opts.Strict:
+			return header[:1+n], fmt.Errorf("%w: padding byte is %q", ErrPadding, pad)
+		case err != nil:
+			return header[:1+n], err
+		}
+		// just an unusual padding byte
+		ar.hdrPos++
+		copy(header, header[1:])
+		m, err := io.ReadFull(ar.r, header[headerSize-1:])
+		ar.pos += int64(m)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return header[:headerSize-1+m], err
+	}
+	n, err := io.ReadFull(ar.r, header)
+	ar.pos += int64(n)
+	if padErr != nil && ar.opts.Strict {
+		return header[:n], padErr
+	}
+	return header[:n], err
+}
+
+// readBSDName reads a BSD long name ("#1/<length>") from the start of the entry's data.
+// The entry's Size is reduced accordingly, to the length of the file proper.
+func (ar *Reader) readBSDName(rawName string, hdr *Header) error {
+	n, err := strconv.ParseInt(rawName[3:], 10, 64)
+	if err != nil || n < 0 || n > hdr.Size || n > maxBSDNameSize {
+		return ErrHeader
+	}
+	name := make([]byte, n)
+	m, err := io.ReadFull(ar.r, name)
+	ar.pos += int64(m)
+	if err != nil {
+		return err
+	}
+	ar.bsdName = name
+	hdr.Name = strings.TrimRight(string(name), "\x00")
+	hdr.Size -= n
+	hdr.Kind = kindOf(hdr.Name)
+	return nil
+}
+
+// HeaderOffset returns the offset within the archive of the current entry's header.
+// Offsets are counted from the start of the archive's "!<arch>" magic, whether or not the source is seekable.
+func (ar *Reader) HeaderOffset() int64 {
+	return ar.hdrPos
+}
+
+// DataOffset returns the offset within the archive of the current entry's data.
+// For entries with a BSD long name, the data follows the name.
+func (ar *Reader) DataOffset() int64 {
+	return ar.dataPos
+}
+
+// RawHeader returns a copy of the current entry's 60-byte header, exactly as read.
+// It returns nil if there is no current entry.
+func (ar *Reader) RawHeader() []byte {
+	if ar.raw == nil {
+		return nil
+	}
+	return append([]byte(nil), ar.raw...)
+}
+
+// checkLimits checks a member header against the Reader's limits.
+func (ar *Reader) checkLimits(hdr *Header) error {
+	ar.members++
+	if max := ar.opts.MaxMembers; max > 0 && ar.members > max {
+		return &LimitError{"member count", hdr.Name, int64(ar.members), int64(max)}
+	}
+	if max := ar.opts.MaxMemberSize; max > 0 && hdr.Size > max {
+		return &LimitError{"member size", hdr.Name, hdr.Size, max}
+	}
+	switch hdr.Kind {
+	case KindLongNameTable:
+		if max := ar.opts.MaxStringTableSize; max > 0 && hdr.Size > max {
+			return &LimitError{"string table size", hdr.Name, hdr.Size, max}
+		}
+	case KindSymbolTable, KindSymbolTable64, KindBSDSymdef, KindCOFFLinkerMember:
+		if max := ar.opts.MaxSymbolTableSize; max > 0 && hdr.Size > max {
+			return &LimitError{"symbol table size", hdr.Name, hdr.Size, max}
+		}
+	}
+	return nil
+}
+
 // Read reads from the current entry in the ar archive.
 // It returns 0, io.EOF when it reaches the end of that entry,
 // until Next is called to advance to the next entry.
@@ -172,6 +418,7 @@ func (ar *Reader) Read(b []byte) (n int, err error) {
 	}
 	n, err = ar.r.Read(b)
 	ar.nb -= int64(n)
+	ar.pos += int64(n)
 
 	if err == io.EOF && ar.nb > 0 {
 		err = io.ErrUnexpectedEOF
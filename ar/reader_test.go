@@ -7,6 +7,7 @@ package ar
 import (
 	"bytes"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -362,3 +363,312 @@ func TestInvalidArHeader(t *testing.T) {
 		t.Errorf("No error returned by NewReader: %v", err)
 	}
 }
+
+func TestReaderLimits(t *testing.T) {
+	member := func(name, size string) string {
+		return fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10s`\n", name, "1405990895", "0", "0", "100644", size)
+	}
+	tests := []struct {
+		archive string
+		opts    *ReaderOptions
+		limit   string
+	}{
+		{member("big.txt", "4") + "abcd", &ReaderOptions{MaxMemberSize: 3}, "member size"},
+		{member("a", "0") + member("b", "0") + member("c", "0"), &ReaderOptions{MaxMembers: 2}, "member count"},
+		{member("//", "8") + "a.txt/\n\n", &ReaderOptions{MaxStringTableSize: 4}, "string table size"},
+		{member("/", "8") + "\x00\x00\x00\x00\x00\x00\x00\x00", &ReaderOptions{MaxSymbolTableSize: 4}, "symbol table size"},
+	}
+	for i, test := range tests {
+		tr, err := NewReaderOptions(strings.NewReader(ArFileHeader+test.archive), test.opts)
+		if err != nil {
+			t.Fatalf("test %d: NewReaderOptions: %v", i, err)
+		}
+		for {
+			_, err = tr.Next()
+			if err != nil {
+				break
+			}
+		}
+		lerr, ok := err.(*LimitError)
+		if !ok {
+			t.Errorf("test %d: got error %v, want a *LimitError", i, err)
+			continue
+		}
+		if lerr.Limit != test.limit {
+			t.Errorf("test %d: exceeded %q, want %q", i, lerr.Limit, test.limit)
+		}
+	}
+}
+
+func TestNegativeSize(t *testing.T) {
+	archive := ArFileHeader + fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10s`\n", "neg.txt", "1405990895", "0", "0", "100644", "-5")
+	tr, err := NewReader(strings.NewReader(archive))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if hdr, err := tr.Next(); err != ErrNegativeSize || hdr != nil {
+		t.Errorf("Next = %v, %v; want nil, %v", hdr, err, ErrNegativeSize)
+	}
+}
+
+func TestOffsets(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/common.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type readerOnly struct {
+		io.Reader
+	}
+	sources := []io.Reader{bytes.NewReader(b), readerOnly{bytes.NewReader(b)}}
+	for i, src := range sources {
+		tr, err := NewReader(src)
+		if err != nil {
+			t.Fatalf("source %d: NewReader: %v", i, err)
+		}
+		if tr.RawHeader() != nil {
+			t.Errorf("source %d: RawHeader before Next should be nil", i)
+		}
+		for j, want := range []int64{8, 74} {
+			if _, err := tr.Next(); err != nil {
+				t.Fatalf("source %d, entry %d: Next: %v", i, j, err)
+			}
+			if j == 1 {
+				// a partial read shouldn't disturb offsets
+				tr.Read(make([]byte, 3))
+			}
+			if got := tr.HeaderOffset(); got != want {
+				t.Errorf("source %d, entry %d: HeaderOffset = %d, want %d", i, j, got, want)
+			}
+			if got := tr.DataOffset(); got != want+headerSize {
+				t.Errorf("source %d, entry %d: DataOffset = %d, want %d", i, j, got, want+headerSize)
+			}
+			if got, want := tr.RawHeader(), b[want:want+headerSize]; !bytes.Equal(got, want) {
+				t.Errorf("source %d, entry %d: RawHeader = %q, want %q", i, j, got, want)
+			}
+		}
+	}
+}
+
+func TestKinds(t *testing.T) {
+	blank := fmt.Sprintf("%-16s%-32s%-10s`\n", "//", "", "8") // GNU leaves the numeric fields of "//" blank
+	tests := []struct {
+		magic   string
+		archive string
+		names   []string
+		kinds   []Kind
+	}{
+		{
+			ArFileHeader,
+			rawHeader("/", "4") + "\x00\x00\x00\x00" + blank + "a.txt/\n\n" + rawHeader("/0", "1") + "a\n",
+			[]string{"", "/", "/0"},
+			[]Kind{KindSymbolTable, KindLongNameTable, KindRegular},
+		},
+		{
+			ArFileHeader,
+			rawHeader("/", "4") + "\x00\x00\x00\x00" + rawHeader("/", "2") + "\x00\x00" + rawHeader("/SYM64/", "0") + rawHeader("b.obj/", "0"),
+			[]string{"", "", "/SYM64", "b.obj"},
+			[]Kind{KindSymbolTable, KindCOFFLinkerMember, KindSymbolTable64, KindRegular},
+		},
+		{
+			thinFileHeader,
+			rawHeader("/", "4") + "\x00\x00\x00\x00" + rawHeader("a.o/", "1234"),
+			[]string{"", "a.o"},
+			[]Kind{KindSymbolTable, KindThinReference},
+		},
+		{
+			ArFileHeader,
+			rawHeader("#1/20", "24") + "__.SYMDEF SORTED\x00\x00\x00\x00abcd" + rawHeader("#1/17", "20") + "a-long-filename.o" + "xyz",
+			[]string{"__.SYMDEF SORTED", "a-long-filename.o"},
+			[]Kind{KindBSDSymdef, KindRegular},
+		},
+	}
+	for i, test := range tests {
+		for _, skip := range []bool{false, true} {
+			tr, err := NewReaderOptions(strings.NewReader(test.magic+test.archive), &ReaderOptions{SkipSpecial: skip})
+			if err != nil {
+				t.Fatalf("test %d: NewReaderOptions: %v", i, err)
+			}
+			var names []string
+			var kinds []Kind
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("test %d: Next: %v", i, err)
+				}
+				names = append(names, hdr.Name)
+				kinds = append(kinds, hdr.Kind)
+			}
+			wantNames, wantKinds := test.names, test.kinds
+			if skip {
+				wantNames, wantKinds = nil, nil
+				for j, k := range test.kinds {
+					if !k.IsSpecial() {
+						wantNames = append(wantNames, test.names[j])
+						wantKinds = append(wantKinds, k)
+					}
+				}
+			}
+			if !reflect.DeepEqual(names, wantNames) || !reflect.DeepEqual(kinds, wantKinds) {
+				t.Errorf("test %d (skip %v): got %q %v, want %q %v", i, skip, names, kinds, wantNames, wantKinds)
+			}
+		}
+	}
+}
+
+func TestBSDLongName(t *testing.T) {
+	archive := ArFileHeader + rawHeader("#1/17", "20") + "a-long-filename.o" + "xyz" + rawHeader("b", "1") + "b\n"
+	tr, err := NewReader(strings.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Size != 3 || tr.DataOffset() != 8+headerSize+17 {
+		t.Errorf("Size = %d, DataOffset = %d; want 3, %d", hdr.Size, tr.DataOffset(), 8+headerSize+17)
+	}
+	body, err := ioutil.ReadAll(tr)
+	if err != nil || string(body) != "xyz" {
+		t.Errorf("data = %q, %v; want \"xyz\"", body, err)
+	}
+	if hdr, err := tr.Next(); err != nil || hdr.Name != "b" {
+		t.Errorf("Next = %v, %v; want b", hdr, err)
+	}
+
+	// CopyFrom keeps the long name
+	tr, _ = NewReader(strings.NewReader(archive))
+	var buf bytes.Buffer
+	tw := NewWriter(&buf)
+	for {
+		if _, err := tr.Next(); err != nil {
+			break
+		}
+		if err := tw.CopyFrom(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != archive {
+		t.Errorf("CopyFrom:\n got %q\nwant %q", buf.String(), archive)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	a := rawHeader("a", "1") + "a\n"
+	b := rawHeader("b", "2") + "bb"
+	c := rawHeader("c", "3") + "ccc\n"
+	damaged := strings.Replace(rawHeader("x", "4"), "`\n", "??", 1) + "xxxx"
+	tests := []struct {
+		name    string
+		archive string
+		want    string
+		skipped []SkippedRegion
+	}{
+		{"intact", a + b + c, "a b c", nil},
+		{"garbage between members", a + "garbage!" + b + c, "a b c", []SkippedRegion{{70, 8, nil}}},
+		{"damaged header", a + damaged + b + c, "a b c", []SkippedRegion{{70, 64, nil}}},
+		{"missing padding", rawHeader("a", "1") + "a" + b + c, "a b c", nil},
+		{"extra padding", a + "\n" + b + c, "a b c", []SkippedRegion{{70, 1, nil}}},
+		{"trailing garbage", a + b + "junk", "a b", []SkippedRegion{{132, 4, nil}}},
+		{"nothing recoverable", a + damaged, "a", []SkippedRegion{{70, 64, nil}}},
+	}
+	for _, test := range tests {
+		var skipped []SkippedRegion
+		opts := &ReaderOptions{Recover: true, OnSkip: func(s SkippedRegion) { skipped = append(skipped, s) }}
+		tr, err := NewReaderOptions(strings.NewReader(ArFileHeader+test.archive), opts)
+		if err != nil {
+			t.Fatalf("%s: NewReaderOptions: %v", test.name, err)
+		}
+		var got []string
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: Next: %v", test.name, err)
+			}
+			body, err := ioutil.ReadAll(tr)
+			if err != nil {
+				t.Fatalf("%s: Read: %v", test.name, err)
+			}
+			if want := strings.Repeat(hdr.Name, int(hdr.Size)); string(body) != want {
+				t.Errorf("%s: %s holds %q, want %q", test.name, hdr.Name, body, want)
+			}
+			got = append(got, hdr.Name)
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("%s: read %q, want %q", test.name, strings.Join(got, " "), test.want)
+		}
+		for i := range skipped {
+			if skipped[i].Err == nil {
+				t.Errorf("%s: skipped region %d has no cause", test.name, i)
+			}
+			skipped[i].Err = nil
+		}
+		if !reflect.DeepEqual(skipped, test.skipped) {
+			t.Errorf("%s: skipped %v, want %v", test.name, skipped, test.skipped)
+		}
+	}
+
+	// without Recover, a damaged header ends the archive
+	tr, _ := NewReader(strings.NewReader(ArFileHeader + a + damaged + b))
+	tr.Next()
+	if _, err := tr.Next(); err != ErrHeader {
+		t.Errorf("Next without Recover = %v, want %v", err, ErrHeader)
+	}
+}
+
+func TestPadding(t *testing.T) {
+	a := rawHeader("a", "1") + "a"
+	b := rawHeader("b", "2") + "bb"
+	tests := []struct {
+		name    string
+		archive string
+		strict  bool // whether Strict mode accepts the archive too
+	}{
+		{"newline", a + "\n" + b, true},
+		{"NUL", a + "\x00" + b, false},
+		{"other byte", a + "X" + b, false},
+		{"omitted", a + b, false},
+		{"newline at end", b + a + "\n", true},
+		{"NUL at end", b + a + "\x00", false},
+		{"omitted at end", b + a, false},
+	}
+	for _, test := range tests {
+		for _, strict := range []bool{false, true} {
+			tr, err := NewReaderOptions(strings.NewReader(ArFileHeader+test.archive), &ReaderOptions{Strict: strict})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			var hdr *Header
+			for {
+				hdr, err = tr.Next()
+				if err != nil {
+					break
+				}
+				body, _ := ioutil.ReadAll(tr)
+				if want := strings.Repeat(hdr.Name, int(hdr.Size)); string(body) != want {
+					t.Errorf("%s (strict %v): %s holds %q, want %q", test.name, strict, hdr.Name, body, want)
+				}
+				names = append(names, hdr.Name)
+			}
+			if strict && !test.strict {
+				if !errors.Is(err, ErrPadding) {
+					t.Errorf("%s (strict): got %v, want %v", test.name, err, ErrPadding)
+				}
+				continue
+			}
+			if err != io.EOF || len(names) != 2 {
+				t.Errorf("%s (strict %v): read %q then %v; want 2 members then EOF", test.name, strict, names, err)
+			}
+		}
+	}
+}
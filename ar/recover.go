@@ -0,0 +1,106 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ar
+
+import (
+	"fmt"
+	"io"
+)
+
+// A SkippedRegion describes part of an archive which a recovering Reader skipped over,
+// while scanning for the next plausible member header.
+type SkippedRegion struct {
+	Offset int64 // offset within the archive of the first byte skipped
+	Length int64 // number of bytes skipped
+	Err    error // the error which made the Reader resynchronise
+}
+
+func (s SkippedRegion) String() string {
+	return fmt.Sprintf("skipped %d bytes at offset %d: %v", s.Length, s.Offset, s.Err)
+}
+
+// scanChunk is the number of bytes read at a time while scanning for a header.
+const scanChunk = 4096
+
+// pushbackReader allows bytes read while scanning to be returned to the stream.
+type pushbackReader struct {
+	r    io.Reader
+	back []byte
+}
+
+func (p *pushbackReader) Read(b []byte) (int, error) {
+	if len(p.back) > 0 {
+		n := copy(b, p.back)
+		p.back = p.back[n:]
+		return n, nil
+	}
+	return p.r.Read(b)
+}
+
+func (p *pushbackReader) unread(b []byte) {
+	p.back = append(append([]byte(nil), b...), p.back...)
+}
+
+// plausibleHeader reports whether h could be a member header:
+// it has the terminating magic, and numeric fields which parse.
+func plausibleHeader(h []byte) bool {
+	if len(h) != headerSize || string(h[headerSize-magicSize:]) != "`\n" {
+		return false
+	}
+	hdr, _, err := parseHeader(h)
+	return err == nil && hdr.Size >= 0
+}
+
+// resync scans forward for the next plausible member header, after cause prevented
+// a header being read at ar.hdrPos. win holds the bytes already read from offset winPos,
+// which may start with the previous member's padding byte, in case it was really
+// the first byte of this header.
+// Bytes between the expected and actual header offsets are reported to OnSkip.
+// If no further header is found, resync reports the rest of the archive as skipped and returns io.EOF.
+func (ar *Reader) resync(win []byte, winPos int64, cause error) ([]byte, error) {
+	expected := ar.hdrPos
+	pr := ar.r.(*pushbackReader)
+	buf := make([]byte, scanChunk)
+	for {
+		for i := 0; i+headerSize <= len(win); i++ {
+			if !plausibleHeader(win[i : i+headerSize]) {
+				continue
+			}
+			found := winPos + int64(i)
+			if found > expected {
+				ar.skipped(expected, found-expected, cause)
+			}
+			pr.unread(win[i+headerSize:])
+			ar.hdrPos = found
+			ar.pos = found + headerSize
+			return win[i : i+headerSize], nil
+		}
+		// the last headerSize-1 bytes may yet start a header
+		if keep := headerSize - 1; len(win) > keep {
+			drop := len(win) - keep
+			win = append(win[:0:0], win[drop:]...)
+			winPos += int64(drop)
+		}
+		n, err := pr.Read(buf)
+		win = append(win, buf[:n]...)
+		if n == 0 && err != nil {
+			if err != io.EOF {
+				return nil, err
+			}
+			end := winPos + int64(len(win))
+			if end > expected {
+				ar.skipped(expected, end-expected, cause)
+			}
+			ar.pos = end
+			return nil, io.EOF
+		}
+	}
+}
+
+func (ar *Reader) skipped(offset, length int64, cause error) {
+	if ar.opts.OnSkip != nil {
+		ar.opts.OnSkip(SkippedRegion{offset, length, cause})
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ar
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// DefaultSpoolLimit is the number of bytes of an entry of unknown size which
+// a Writer holds in memory before spilling to a temporary file.
+const DefaultSpoolLimit = 32 << 20
+
+// spool buffers the data of an entry whose size is not yet known.
+// Data is kept in memory up to limit bytes, and then moved to a temporary file.
+type spool struct {
+	limit int64
+	dir   string
+	buf   bytes.Buffer
+	f     *os.File
+	n     int64
+}
+
+func (s *spool) Write(b []byte) (int, error) {
+	if s.f == nil && s.n+int64(len(b)) > s.limit {
+		f, err := ioutil.TempFile(s.dir, "argo-spool-")
+		if err != nil {
+			return 0, err
+		}
+		s.f = f
+		if _, err := s.buf.WriteTo(f); err != nil {
+			return 0, err
+		}
+	}
+	var n int
+	var err error
+	if s.f != nil {
+		n, err = s.f.Write(b)
+	} else {
+		n, err = s.buf.Write(b)
+	}
+	s.n += int64(n)
+	return n, err
+}
+
+// WriteTo copies the spooled data to w.
+func (s *spool) WriteTo(w io.Writer) (int64, error) {
+	if s.f == nil {
+		return s.buf.WriteTo(w)
+	}
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, s.f)
+}
+
+// Close releases the spool's temporary file, if any.
+func (s *spool) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	name := s.f.Name()
+	err := s.f.Close()
+	if rerr := os.Remove(name); err == nil {
+		err = rerr
+	}
+	s.f = nil
+	return err
+}
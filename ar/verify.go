@@ -0,0 +1,431 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severity grades a Problem found by Verify.
+type Severity int
+
+const (
+	// SeverityWarning is a deviation from the format which common tools tolerate
+	SeverityWarning Severity = iota
+	// SeverityError is damage which prevents members from being read correctly
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// A Problem describes a single defect found by Verify.
+type Problem struct {
+	Offset   int64 // offset within the archive at which the problem was found
+	Severity Severity
+	Member   string // name field of the member concerned, as written in its header
+	Message  string
+}
+
+func (p Problem) String() string {
+	if p.Member != "" {
+		return fmt.Sprintf("0x%08x %s: %s: %s", p.Offset, p.Severity, p.Member, p.Message)
+	}
+	return fmt.Sprintf("0x%08x %s: %s", p.Offset, p.Severity, p.Message)
+}
+
+// Verify checks an entire archive, reporting every problem it finds rather than stopping at the first.
+// It checks the archive and member header magic, numeric header fields, member data and padding,
+// trailing bytes, symbol table offsets (GNU, 64-bit GNU and BSD __.SYMDEF), GNU string table references,
+// BSD long names, and duplicate member names.
+// An archive which verifies cleanly yields no problems.
+func Verify(r io.ReaderAt, size int64) []Problem {
+	v := &verifier{r: r, size: size, headers: make(map[int64]bool)}
+	v.run()
+	return v.problems
+}
+
+type verifiedMember struct {
+	offset int64
+	name   string
+}
+
+type symbolTable struct {
+	offset int64 // offset of the table's data
+	name   string
+	data   []byte
+}
+
+type longNameRef struct {
+	offset int64 // offset of the referring header
+	index  int64
+}
+
+type verifier struct {
+	r        io.ReaderAt
+	size     int64
+	thin     bool
+	problems []Problem
+
+	headers   map[int64]bool // offsets of every member header
+	members   []verifiedMember
+	symtabs   []symbolTable
+	strtab    []byte
+	hasStrtab bool
+	longRefs  []longNameRef
+}
+
+func (v *verifier) add(offset int64, sev Severity, member, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{offset, sev, member, fmt.Sprintf(format, args...)})
+}
+
+// readAt reads up to n bytes at offset, returning fewer at the end of the archive.
+func (v *verifier) readAt(offset int64, n int64) []byte {
+	if offset+n > v.size {
+		n = v.size - offset
+	}
+	if n <= 0 {
+		return nil
+	}
+	b := make([]byte, n)
+	m, _ := v.r.ReadAt(b, offset)
+	return b[:m]
+}
+
+func (v *verifier) run() {
+	magic := v.readAt(0, arHeaderSize)
+	switch {
+	case len(magic) < arHeaderSize:
+		v.add(0, SeverityError, "", "archive is %d bytes, too short for the %q magic", len(magic), ArFileHeader)
+		return
+	case string(magic) == thinFileHeader:
+		v.thin = true
+	case string(magic) != ArFileHeader:
+		v.add(0, SeverityError, "", "bad archive magic %q, want %q", magic, ArFileHeader)
+	}
+	offset := int64(arHeaderSize)
+	for offset < v.size {
+		if remaining := v.size - offset; remaining < headerSize {
+			v.add(offset, SeverityError, "", "%d bytes of trailing garbage", remaining)
+			break
+		}
+		next, ok := v.member(offset)
+		if !ok {
+			break
+		}
+		offset = next
+	}
+	v.checkLongNames()
+	v.checkSymbolTables()
+	v.checkDuplicates()
+	sort.SliceStable(v.problems, func(i, j int) bool { return v.problems[i].Offset < v.problems[j].Offset })
+}
+
+// member checks the member at offset, returning the offset of the next header.
+// ok is false if the member is too damaged to find the next header, even by scanning forward.
+func (v *verifier) member(offset int64) (next int64, ok bool) {
+	h := v.readAt(offset, headerSize)
+	if len(h) < headerSize {
+		// the archive is shorter than its stated size, or could not be read
+		v.add(offset, SeverityError, "", "data truncated: %d of %d header bytes present", len(h), headerSize)
+		return v.size, false
+	}
+	s := slicer(h)
+	rawName := string(s.next(fileNameSize))
+	name := strings.TrimRight(rawName, " ")
+	fields := []struct {
+		label string
+		value []byte
+		base  int
+	}{
+		{"modification time", s.next(modTimeSize), 10},
+		{"uid", s.next(uidSize), 10},
+		{"gid", s.next(gidSize), 10},
+		{"mode", s.next(modeSize), 8},
+	}
+	sizeField := s.next(sizeSize)
+	magic := s.next(magicSize)
+
+	if string(magic) != "`\n" {
+		v.add(offset+headerSize-magicSize, SeverityError, name, "bad header magic %q, want %q", magic, "`\n")
+	}
+	fieldOffset := offset + fileNameSize
+	for _, f := range fields {
+		v.numericField(fieldOffset, name, f.label, f.value, f.base)
+		fieldOffset += int64(len(f.value))
+	}
+	sizeStr := strings.TrimRight(string(sizeField), " ")
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	switch {
+	case err != nil:
+		v.add(fieldOffset, SeverityError, name, "size %q is not a decimal number", sizeField)
+		return v.resync(offset + headerSize)
+	case size < 0:
+		v.add(fieldOffset, SeverityError, name, "negative size %d", size)
+		return v.resync(offset + headerSize)
+	}
+	v.headers[offset] = true
+
+	dataOffset := offset + headerSize
+	end := dataOffset + size
+	special := v.classify(offset, dataOffset, name, size)
+	if v.thin && !special {
+		// thin archives only hold headers for regular members
+		return dataOffset, true
+	}
+	if end > v.size {
+		v.add(dataOffset, SeverityError, name, "data truncated: %d of %d bytes present", v.size-dataOffset, size)
+		return v.size, false
+	}
+	if size%2 == 0 {
+		return end, true
+	}
+	if end == v.size {
+		v.add(end, SeverityWarning, name, "padding byte missing at end of archive")
+		return end, true
+	}
+	switch pad := v.readAt(end, 1); {
+	case len(pad) == 0:
+		v.add(end, SeverityError, name, "data truncated: padding byte could not be read")
+		return v.size, false
+	case pad[0] == '\n':
+		return end + 1, true
+	case v.looksLikeHeader(end):
+		v.add(end, SeverityWarning, name, "padding byte missing")
+		return end, true
+	default:
+		v.add(end, SeverityWarning, name, "padding byte is %q, want %q", pad, "\n")
+		return end + 1, true
+	}
+}
+
+// resync scans forward from offset for the next plausible member header, as a recovering Reader does,
+// after a header whose size cannot be trusted. The bytes passed over are reported.
+// ok is false if no further header is found.
+func (v *verifier) resync(offset int64) (next int64, ok bool) {
+	for pos := offset; pos+headerSize <= v.size; pos += scanChunk {
+		win := v.readAt(pos, scanChunk+headerSize-1)
+		for i := 0; i+headerSize <= len(win); i++ {
+			if plausibleHeader(win[i : i+headerSize]) {
+				found := pos + int64(i)
+				v.add(offset, SeverityWarning, "", "skipped %d bytes to the next plausible member header", found-offset)
+				return found, true
+			}
+		}
+	}
+	v.add(offset, SeverityError, "", "no member header found in the remaining %d bytes", v.size-offset)
+	return v.size, false
+}
+
+// numericField checks a header field holds a left-aligned, space-padded number.
+// Blank fields are accepted, as GNU ar leaves them blank for its string table.
+func (v *verifier) numericField(offset int64, member, label string, value []byte, base int) {
+	trimmed := strings.TrimRight(string(value), " ")
+	if trimmed == "" {
+		return
+	}
+	if strings.TrimLeft(trimmed, " ") != trimmed {
+		v.add(offset, SeverityWarning, member, "%s %q is not left-aligned", label, value)
+		trimmed = strings.TrimLeft(trimmed, " ")
+	}
+	if _, err := strconv.ParseUint(trimmed, base, 64); err != nil {
+		if base == 8 {
+			v.add(offset, SeverityError, member, "%s %q is not an octal number", label, value)
+		} else {
+			v.add(offset, SeverityError, member, "%s %q is not a decimal number", label, value)
+		}
+	}
+}
+
+// looksLikeHeader reports whether a plausible member header starts at offset.
+func (v *verifier) looksLikeHeader(offset int64) bool {
+	h := v.readAt(offset, headerSize)
+	if len(h) < headerSize || string(h[headerSize-magicSize:]) != "`\n" {
+		return false
+	}
+	sizeStr := strings.TrimSpace(string(h[headerSize-magicSize-sizeSize : headerSize-magicSize]))
+	_, err := strconv.ParseUint(sizeStr, 10, 64)
+	return err == nil
+}
+
+// classify records a member for the cross-reference checks,
+// reporting whether it is one of the archive's special members.
+func (v *verifier) classify(offset, dataOffset int64, name string, size int64) (special bool) {
+	switch {
+	case name == "/" || name == "/SYM64/":
+		if dataOffset+size <= v.size {
+			v.symtabs = append(v.symtabs, symbolTable{dataOffset, name, v.readAt(dataOffset, size)})
+		}
+		return true
+	case name == "//":
+		if v.hasStrtab {
+			v.add(offset, SeverityError, name, "second string table")
+		}
+		v.hasStrtab = true
+		v.strtab = v.readAt(dataOffset, size)
+		return true
+	case strings.HasPrefix(name, "/") && len(name) > 1:
+		index, err := strconv.ParseInt(name[1:], 10, 64)
+		if err != nil {
+			v.add(offset, SeverityError, name, "malformed long name reference")
+			return false
+		}
+		v.longRefs = append(v.longRefs, longNameRef{offset, index})
+		return false
+	case strings.HasPrefix(name, "#1/"):
+		n, err := strconv.ParseInt(name[3:], 10, 64)
+		if err != nil || n < 0 {
+			v.add(offset, SeverityError, name, "malformed BSD long name length")
+			return false
+		}
+		if n > size {
+			v.add(offset, SeverityError, name, "BSD long name length %d exceeds member size %d", n, size)
+			return false
+		}
+		name = string(bytes.TrimRight(v.readAt(dataOffset, n), "\x00"))
+		if isBSDSymdef(name) {
+			if dataOffset+size <= v.size {
+				v.symtabs = append(v.symtabs, symbolTable{dataOffset + n, name, v.readAt(dataOffset+n, size-n)})
+			}
+			return true
+		}
+	case isBSDSymdef(name):
+		if dataOffset+size <= v.size {
+			v.symtabs = append(v.symtabs, symbolTable{dataOffset, name, v.readAt(dataOffset, size)})
+		}
+		return true
+	}
+	v.members = append(v.members, verifiedMember{offset, strings.TrimSuffix(name, "/")})
+	return false
+}
+
+func isBSDSymdef(name string) bool {
+	return name == "__.SYMDEF" || name == "__.SYMDEF SORTED" || name == "__.SYMDEF_64" || name == "__.SYMDEF_64 SORTED"
+}
+
+// checkLongNames resolves GNU long name references against the string table.
+func (v *verifier) checkLongNames() {
+	for _, ref := range v.longRefs {
+		label := fmt.Sprintf("/%d", ref.index)
+		switch {
+		case !v.hasStrtab:
+			v.add(ref.offset, SeverityError, label, "long name reference, but the archive has no string table")
+			continue
+		case ref.index >= int64(len(v.strtab)):
+			v.add(ref.offset, SeverityError, label, "long name reference beyond the %d byte string table", len(v.strtab))
+			continue
+		}
+		entry := v.strtab[ref.index:]
+		end := bytes.IndexByte(entry, '\n')
+		if end < 0 {
+			v.add(ref.offset, SeverityError, label, "unterminated string table entry")
+			continue
+		}
+		v.members = append(v.members, verifiedMember{ref.offset, strings.TrimSuffix(string(entry[:end]), "/")})
+	}
+}
+
+// checkSymbolTables checks that every symbol table offset points at a member header.
+func (v *verifier) checkSymbolTables() {
+	linkerMembers := 0
+	for _, st := range v.symtabs {
+		var offsets []int64
+		var entryOffsets []int64
+		switch st.name {
+		case "/":
+			linkerMembers++
+			if linkerMembers > 1 {
+				// the second linker member of a COFF archive has a different, little-endian layout
+				continue
+			}
+			offsets, entryOffsets = gnuSymbolOffsets(st.data, 4)
+		case "/SYM64/":
+			offsets, entryOffsets = gnuSymbolOffsets(st.data, 8)
+		default:
+			offsets, entryOffsets = bsdSymbolOffsets(st.data)
+		}
+		if offsets == nil && len(st.data) > 0 {
+			v.add(st.offset, SeverityError, st.name, "symbol table is truncated")
+			continue
+		}
+		for i, target := range offsets {
+			if !v.headers[target] {
+				v.add(st.offset+entryOffsets[i], SeverityError, st.name, "symbol %d refers to offset %d, which is not a member header", i, target)
+			}
+		}
+	}
+}
+
+// gnuSymbolOffsets decodes the big-endian member offsets of a GNU symbol table,
+// along with the position of each within the table.
+func gnuSymbolOffsets(data []byte, width int) (offsets, positions []int64) {
+	if len(data) < width {
+		return nil, nil
+	}
+	count := readUint(data, width)
+	if count > uint64(len(data)/width-1) {
+		return nil, nil
+	}
+	offsets = make([]int64, count)
+	positions = make([]int64, count)
+	for i := range offsets {
+		pos := width * (i + 1)
+		offsets[i] = int64(readUint(data[pos:], width))
+		positions[i] = int64(pos)
+	}
+	return offsets, positions
+}
+
+func readUint(b []byte, width int) uint64 {
+	if width == 8 {
+		return binary.BigEndian.Uint64(b)
+	}
+	return uint64(binary.BigEndian.Uint32(b))
+}
+
+// bsdSymbolOffsets decodes the member offsets of a BSD __.SYMDEF table:
+// a byte count followed by (string index, member offset) pairs, in the byte order of the host which built it.
+func bsdSymbolOffsets(data []byte) (offsets, positions []int64) {
+	if len(data) < 4 {
+		return nil, nil
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		n := int64(order.Uint32(data))
+		if n%8 != 0 || n > int64(len(data)-4) {
+			continue
+		}
+		offsets = make([]int64, 0, n/8)
+		positions = make([]int64, 0, n/8)
+		for pos := int64(4); pos < 4+n; pos += 8 {
+			offsets = append(offsets, int64(order.Uint32(data[pos+4:])))
+			positions = append(positions, pos+4)
+		}
+		return offsets, positions
+	}
+	return nil, nil
+}
+
+// checkDuplicates reports members sharing a name. ar permits this, but it is rarely intended.
+func (v *verifier) checkDuplicates() {
+	sort.Slice(v.members, func(i, j int) bool { return v.members[i].offset < v.members[j].offset })
+	first := make(map[string]int64)
+	for _, m := range v.members {
+		if prev, ok := first[m.name]; ok {
+			v.add(m.offset, SeverityWarning, m.name, "duplicate member name (first at offset %d)", prev)
+			continue
+		}
+		first[m.name] = m.offset
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// rawHeader formats a member header, without any validation.
+func rawHeader(name, size string) string {
+	return fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10s`\n", name, "1405990895", "1000", "1000", "100664", size)
+}
+
+func verifyString(s string) []Problem {
+	return Verify(strings.NewReader(s), int64(len(s)))
+}
+
+// wantProblems checks that problems match the given messages, in order.
+func wantProblems(t *testing.T, name string, problems []Problem, want ...string) {
+	if len(problems) != len(want) {
+		t.Errorf("%s: got %d problems, want %d: %v", name, len(problems), len(want), problems)
+		return
+	}
+	for i, w := range want {
+		if !strings.Contains(problems[i].Message, w) {
+			t.Errorf("%s: problem %d = %v, want %q", name, i, problems[i], w)
+		}
+	}
+}
+
+func TestVerifyClean(t *testing.T) {
+	for _, file := range []string{"testdata/common.ar", "testdata/writer.ar"} {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if problems := Verify(bytes.NewReader(b), int64(len(b))); len(problems) != 0 {
+			t.Errorf("%s: unexpected problems: %v", file, problems)
+		}
+	}
+}
+
+func TestVerifyNoFooter(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/nofooter.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := Verify(bytes.NewReader(b), int64(len(b)))
+	wantProblems(t, "nofooter.ar", problems, "data truncated: 10 of 11 bytes present")
+	if problems[0].Severity != SeverityError || problems[0].Member != "small2.txt/" || problems[0].Offset != 0x86 {
+		t.Errorf("Unexpected problem details: %+v", problems[0])
+	}
+}
+
+func TestVerifyShortRead(t *testing.T) {
+	// a ReaderAt holding less than the stated size, as with a truncated download
+	odd := ArFileHeader + rawHeader("a.txt", "3") + "abc"
+	for _, test := range []struct {
+		name string
+		data string
+		want string
+	}{
+		{"short header", odd + "\n" + rawHeader("b.txt", "2")[:20], "data truncated: 20 of 60 header bytes present"},
+		{"missing padding", odd, "data truncated: padding byte could not be read"},
+	} {
+		problems := Verify(strings.NewReader(test.data), int64(len(test.data))+100)
+		wantProblems(t, test.name, problems, test.want)
+	}
+}
+
+func TestVerifyHeaders(t *testing.T) {
+	badFields := ArFileHeader + fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10s`\n", "a.txt", "12x", "-1", "1000", "100698", "2") + "hi"
+	wantProblems(t, "bad fields", verifyString(badFields),
+		"modification time", "uid", "mode")
+
+	badMagic := ArFileHeader + rawHeader("a.txt", "2")[:58] + "XX" + "hi"
+	wantProblems(t, "bad magic", verifyString(badMagic), "bad header magic")
+
+	// the walk carries on past a member whose size is unusable, and finds later problems
+	badSize := ArFileHeader + rawHeader("a.txt", "two") + "hi" + rawHeader("b.txt", "2") + "hi" + rawHeader("c.txt", "-4") + "junk" + rawHeader("d.txt", "3") + "abc"
+	problems := verifyString(badSize)
+	wantProblems(t, "bad size", problems,
+		"size \"two", "skipped 2 bytes", "negative size", "skipped 4 bytes", "padding byte missing at end")
+	if problems[4].Member != "d.txt" {
+		t.Errorf("last problem is for %q, want d.txt", problems[4].Member)
+	}
+	wantProblems(t, "bad size at end", verifyString(ArFileHeader+rawHeader("a.txt", "x")+"hi"), "not a decimal", "no member header found")
+
+	wantProblems(t, "archive magic", verifyString("!<arkh>\n"+rawHeader("a.txt", "2")+"hi"), "bad archive magic")
+	wantProblems(t, "short", verifyString("!<ar"), "too short")
+}
+
+func TestVerifyPadding(t *testing.T) {
+	wantProblems(t, "good", verifyString(ArFileHeader+rawHeader("a.txt", "1")+"a\n"+rawHeader("b.txt", "1")+"b\n"))
+	wantProblems(t, "wrong pad", verifyString(ArFileHeader+rawHeader("a.txt", "1")+"a\x00"+rawHeader("b.txt", "1")+"b\n"),
+		"padding byte is")
+	wantProblems(t, "missing pad", verifyString(ArFileHeader+rawHeader("a.txt", "1")+"a"+rawHeader("b.txt", "1")+"b\n"),
+		"padding byte missing")
+	wantProblems(t, "missing final pad", verifyString(ArFileHeader+rawHeader("a.txt", "1")+"a"),
+		"padding byte missing at end")
+	wantProblems(t, "trailing garbage", verifyString(ArFileHeader+rawHeader("a.txt", "2")+"ab"+"junk"),
+		"4 bytes of trailing garbage")
+}
+
+func TestVerifyTables(t *testing.T) {
+	// a GNU archive with a symbol table, a string table and a long name
+	strtab := "a_very_long_member_name.o/\n"
+	symtabSize := 4 + 2*4 + len("foo\x00bar\x00")
+	var symtab bytes.Buffer
+	binary.Write(&symtab, binary.BigEndian, uint32(2))
+	firstMember := int64(arHeaderSize + headerSize + symtabSize + headerSize + len(strtab) + 1)
+	binary.Write(&symtab, binary.BigEndian, uint32(firstMember))
+	binary.Write(&symtab, binary.BigEndian, uint32(firstMember+1)) // not a header
+	symtab.WriteString("foo\x00bar\x00")
+	archive := ArFileHeader +
+		rawHeader("/", fmt.Sprint(symtabSize)) + symtab.String() +
+		rawHeader("//", fmt.Sprint(len(strtab))) + strtab + "\n" +
+		rawHeader("/0", "2") + "hi" +
+		rawHeader("/99", "2") + "hi" +
+		rawHeader("dup.o/", "2") + "hi" +
+		rawHeader("dup.o/", "2") + "hi"
+	wantProblems(t, "tables", verifyString(archive),
+		"symbol 1 refers to offset", "beyond the 27 byte string table", "duplicate member name")
+}
@@ -0,0 +1,44 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ar
+
+import (
+	"io"
+)
+
+// WalkFunc is called by Walk for each member of an archive.
+// r reads the member's data, and is only valid until WalkFunc returns.
+// If WalkFunc returns an error, Walk stops and returns that error.
+type WalkFunc func(hdr *Header, r io.Reader) error
+
+// Walk reads the ar archive from r, calling fn for each member in turn.
+// It returns nil at the end of the archive, or the first error from reading the archive or from fn.
+func Walk(r io.Reader, fn WalkFunc) error {
+	ar, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	return ar.Walk(fn)
+}
+
+// Walk calls fn for each remaining member of the archive.
+// It returns nil at the end of the archive, or the first error from reading the archive or from fn.
+func (ar *Reader) Walk(fn WalkFunc) error {
+	for {
+		hdr, err := ar.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr == nil {
+			return ErrHeader
+		}
+		if err := fn(hdr, ar); err != nil {
+			return err
+		}
+	}
+}
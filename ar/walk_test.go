@@ -0,0 +1,62 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ar
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	f, err := os.Open("testdata/common.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var names, bodies []string
+	err = Walk(f, func(hdr *Header, r io.Reader) error {
+		body, err := ioutil.ReadAll(r)
+		names = append(names, hdr.Name)
+		bodies = append(bodies, string(body))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	if want := []string{"small.txt", "small2.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+	if want := []string{"Kilts", "Google.com\n"}; !reflect.DeepEqual(bodies, want) {
+		t.Errorf("bodies = %q, want %q", bodies, want)
+	}
+}
+
+func TestWalkErrors(t *testing.T) {
+	stop := errors.New("stop")
+	archive := ArFileHeader + rawHeader("a", "1") + "a\n" + rawHeader("b", "1") + "b\n"
+	calls := 0
+	err := Walk(strings.NewReader(archive), func(*Header, io.Reader) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Walk = %v after %d calls, want %v after 1", err, calls, stop)
+	}
+
+	// a truncated archive
+	err = Walk(strings.NewReader(archive[:len(archive)-30]), func(*Header, io.Reader) error { return nil })
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Walk of truncated archive = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	if err := Walk(strings.NewReader("not an archive"), func(*Header, io.Reader) error { return nil }); err == nil {
+		t.Error("Walk of invalid archive succeeded")
+	}
+}
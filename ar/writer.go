@@ -15,11 +15,44 @@ import (
 	"strings"
 )
 
+// UnknownSize may be given as a Header's Size to write an entry whose length is not known in advance.
+// If the underlying writer is an io.WriteSeeker, the size field is patched in place once the entry is finished.
+// Otherwise the entry's data is spooled, in memory up to SpoolLimit bytes and then in a temporary file,
+// and written out along with its header once the entry is finished.
+const UnknownSize = -1
+
 var (
 	//ErrWriteAfterClose shows that a write was attempted after the archive has been closed (and the footer is written)
 	ErrWriteAfterClose = errors.New("ar: write after close")
-	errNameTooLong     = errors.New("ar: name too long")
-	errInvalidHeader   = errors.New("ar: header field too long or contains invalid values")
+	//ErrWriteTooLong shows that more than the header's Size bytes were written to an entry
+	ErrWriteTooLong = errors.New("ar: write too long")
+	//ErrWriteTooShort shows that fewer than the header's Size bytes were written to an entry before it was finished
+	ErrWriteTooShort = errors.New("ar: write too short")
+	errCopyAfterRead = errors.New("ar: CopyFrom requires an entry whose data has not been read")
+	errNoEntry       = errors.New("ar: no current entry to copy")
+	errCopyThin      = errors.New("ar: cannot copy a thin archive member, whose data is held in an external file")
+	//ErrFieldTooLong shows that a Header field does not fit in its ar header field
+	ErrFieldTooLong = errors.New("ar: header field too long")
+	//ErrFieldNegative shows that a Header field, which ar stores unsigned, is negative
+	ErrFieldNegative = errors.New("ar: header field negative")
+	//ErrFieldNotOctal shows that a Header's Mode has a digit which is not octal, such as 0755 given as 493 rather than 755
+	ErrFieldNotOctal = errors.New("ar: header field not octal")
+	//ErrDuplicateName shows that a member was refused because an earlier member has the same name
+	ErrDuplicateName = errors.New("ar: duplicate member name")
+)
+
+// DuplicatePolicy determines how a Writer treats a member with the same name as an earlier member.
+type DuplicatePolicy int
+
+const (
+	// AllowDuplicates writes members with duplicate names, as ar permits.
+	AllowDuplicates DuplicatePolicy = iota
+	// RefuseDuplicates makes WriteHeader and CopyFrom return ErrDuplicateName for a duplicate.
+	RefuseDuplicates
+	// ReplaceDuplicates replaces the earlier member in place, as 'ar r' does.
+	// As any member may yet be replaced, nothing after the archive header is written
+	// to the underlying writer until Close. Members are spooled meanwhile, as for UnknownSize.
+	ReplaceDuplicates
 )
 
 // A Writer provides sequential writing of an ar archive.
@@ -30,10 +63,22 @@ type Writer struct {
 	w                       io.Writer
 	arFileHeaderWritten     bool
 	err                     error
-	nb                      int64 // number of unwritten bytes for current file entry
-	pad                     bool  // whether the file will be padded an extra byte (i.e. if ther's an odd number of bytes in the file)
+	nb                      int64  // number of unwritten bytes for current file entry
+	name                    string // name of current file entry, for error reporting
+	pad                     bool   // whether the file will be padded an extra byte (i.e. if ther's an odd number of bytes in the file)
+	padding                 []byte // padding byte copied from another archive by CopyFrom, to write in place of "\n"
 	closed                  bool
-	TerminateFilenamesSlash bool // This flag determines whether to terminate filenames with a slash '/' or not. GNU ar uses slashes, whereas .deb files tend not to use them.
+	unknown                 *Header         // header of the current entry, if it was written with UnknownSize
+	sizeOffset              int64           // offset of the current entry's size field, when it is patched in place
+	spool                   *spool          // data of the current entry, when its size is unknown and w cannot seek
+	written                 int64           // number of bytes written to an entry of unknown size
+	TerminateFilenamesSlash bool            // This flag determines whether to terminate filenames with a slash '/' or not. GNU ar uses slashes, whereas .deb files tend not to use them.
+	SpoolLimit              int64           // maximum bytes of an entry of UnknownSize held in memory before spooling to a temporary file. Zero means DefaultSpoolLimit.
+	SpoolDir                string          // directory for spool files. Empty means the default temporary directory.
+	Duplicates              DuplicatePolicy // treatment of duplicate member names. Set before the first WriteHeader.
+	names                   map[string]int  // names written so far, with their position in pending
+	pending                 []*spool        // encoded members awaiting Close, with ReplaceDuplicates
+	out                     io.Writer       // the underlying writer, while w is a member's spool
 }
 
 // NewWriter creates a new Writer writing to w.
@@ -42,9 +87,14 @@ func NewWriter(w io.Writer) *Writer { return &Writer{w: w} }
 // Flush finishes writing the current file (optional. This is called by writeHeader anyway.)
 func (aw *Writer) Flush() error {
 	if aw.nb > 0 {
-		aw.err = fmt.Errorf("ar: missed writing %d bytes", aw.nb)
+		aw.err = fmt.Errorf("%w: missed writing %d bytes of %q", ErrWriteTooShort, aw.nb, aw.name)
 		return aw.err
 	}
+	if aw.unknown != nil {
+		if aw.err = aw.finishUnknown(); aw.err != nil {
+			return aw.err
+		}
+	}
 	if !aw.arFileHeaderWritten {
 		_, aw.err = aw.w.Write([]byte(ArFileHeader))
 		if aw.err != nil {
@@ -53,18 +103,24 @@ func (aw *Writer) Flush() error {
 		aw.arFileHeaderWritten = true
 	}
 	if aw.pad {
-		//pad with a newline
-		if _, aw.err = aw.w.Write([]byte("\n")); aw.err != nil {
+		//pad with a newline, unless the entry was copied with other padding
+		padding := aw.padding
+		if padding == nil {
+			padding = []byte("\n")
+		}
+		if _, aw.err = aw.w.Write(padding); aw.err != nil {
 			return aw.err
 		}
 	}
 	aw.nb = 0
 	aw.pad = false
+	aw.padding = nil
 	return aw.err
 }
 
 // WriteHeader writes hdr and prepares to accept the file's contents.
 // WriteHeader calls Flush if it is not the first header.
+// If hdr.Size is UnknownSize, the entry's size is taken from the data written before the next Flush.
 // Calling after a Close will return ErrWriteAfterClose.
 func (aw *Writer) WriteHeader(hdr *Header) error {
 	return aw.writeHeader(hdr)
@@ -83,50 +139,252 @@ func (aw *Writer) writeHeader(hdr *Header) error {
 	if aw.err != nil {
 		return aw.err
 	}
-	fmodTimestamp := fmt.Sprintf("%d", hdr.ModTime.Unix())
-	//use root by default (this is particularly useful for debs).
-	uid := fmt.Sprintf("%d", hdr.Uid)
-	if len(uid) > 6 {
-		return fmt.Errorf("UID too long")
+	// validate before beginEntry, so that a bad header leaves no trace in the duplicate policy
+	h := *hdr
+	if h.Size == UnknownSize {
+		h.Size = 0
 	}
-	gid := fmt.Sprintf("%d", hdr.Gid)
-	if len(gid) > 6 {
-		return fmt.Errorf("GID too long")
+	if _, err := h.Encode(aw.format()); err != nil {
+		return err
 	}
-	//Files only atm (not dirs)
-	mode := fmt.Sprintf("100%d", hdr.Mode)
-	size := fmt.Sprintf("%d", hdr.Size)
-	name := hdr.Name
-	if aw.TerminateFilenamesSlash {
-		name += "/"
+	if err := aw.beginEntry(hdr.Name); err != nil {
+		return err
+	}
+	if hdr.Size == UnknownSize {
+		return aw.beginUnknown(hdr)
 	}
-	line := fmt.Sprintf("%s%s%s%s%s%s`\n", pad(name, 16), pad(fmodTimestamp, 12), pad(gid, 6), pad(uid, 6), pad(mode, 8), pad(size, 10))
-	if _, err := aw.Write([]byte(line)); err != nil {
+	return aw.writeHeaderLine(hdr)
+}
+
+// writeHeaderLine encodes and writes hdr, and prepares to accept hdr.Size bytes.
+func (aw *Writer) writeHeaderLine(hdr *Header) error {
+	line, err := hdr.Encode(aw.format())
+	if err != nil {
 		return err
 	}
+	if _, aw.err = aw.w.Write(line); aw.err != nil {
+		return aw.err
+	}
 	// data section is 2-byte aligned.
 	if hdr.Size%2 == 1 {
 		aw.pad = true
 	}
 	aw.nb = hdr.Size
+	aw.name = hdr.Name
+	return nil
+}
+
+// format returns the header format selected by TerminateFilenamesSlash.
+func (aw *Writer) format() Format {
+	if aw.TerminateFilenamesSlash {
+		return FormatGNU
+	}
+	return FormatCommon
+}
+
+// beginUnknown starts an entry of unknown size.
+// A placeholder header is written immediately if it can be patched later.
+func (aw *Writer) beginUnknown(hdr *Header) error {
+	h := *hdr
+	aw.unknown = &h
+	aw.written = 0
+	if ws, ok := aw.w.(io.WriteSeeker); ok {
+		if offset, err := ws.Seek(0, io.SeekCurrent); err == nil {
+			h.Size = 0
+			if aw.err = aw.writeHeaderLine(&h); aw.err != nil {
+				return aw.err
+			}
+			aw.sizeOffset = offset + fileNameSize + modTimeSize + uidSize + gidSize + modeSize
+			return nil
+		}
+	}
+	aw.spool = aw.newSpool()
+	return nil
+}
+
+func (aw *Writer) newSpool() *spool {
+	limit := aw.SpoolLimit
+	if limit == 0 {
+		limit = DefaultSpoolLimit
+	}
+	return &spool{limit: limit, dir: aw.SpoolDir}
+}
+
+// beginEntry applies the duplicate name policy to a new entry.
+// With ReplaceDuplicates, it directs the entry to its own spool.
+func (aw *Writer) beginEntry(name string) error {
+	if aw.names == nil {
+		aw.names = make(map[string]int)
+	}
+	i, dup := aw.names[name]
+	switch aw.Duplicates {
+	case RefuseDuplicates:
+		if dup {
+			return fmt.Errorf("%w: %q", ErrDuplicateName, name)
+		}
+	case ReplaceDuplicates:
+		if aw.out == nil {
+			aw.out = aw.w
+		}
+		sp := aw.newSpool()
+		if dup {
+			aw.pending[i].Close()
+			aw.pending[i] = sp
+		} else {
+			i = len(aw.pending)
+			aw.pending = append(aw.pending, sp)
+		}
+		aw.w = sp
+	}
+	aw.names[name] = i
+	return nil
+}
+
+// finishUnknown completes an entry of unknown size, now that its size is known.
+func (aw *Writer) finishUnknown() error {
+	hdr := aw.unknown
+	aw.unknown = nil
+	hdr.Size = aw.written
+	if aw.spool != nil {
+		sp := aw.spool
+		aw.spool = nil
+		defer sp.Close()
+		if err := aw.writeHeaderLine(hdr); err != nil {
+			return err
+		}
+		if _, err := sp.WriteTo(aw.w); err != nil {
+			return err
+		}
+		aw.nb = 0
+		return nil
+	}
+	ws := aw.w.(io.WriteSeeker)
+	end, err := ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	size, err := formatField("size", hdr.Size, sizeSize)
+	if err != nil {
+		return err
+	}
+	if _, err := ws.Seek(aw.sizeOffset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(ws, pad(size, sizeSize)); err != nil {
+		return err
+	}
+	if _, err := ws.Seek(end, io.SeekStart); err != nil {
+		return err
+	}
+	aw.pad = hdr.Size%2 == 1
 	return nil
 }
 
-// Write some data to the ar file.
+// Write writes to the current entry in the ar archive.
+// Write returns the error ErrWriteTooLong if more than
+// hdr.Size bytes are written after WriteHeader.
 func (aw *Writer) Write(b []byte) (int, error) {
 	if aw.closed {
 		aw.err = ErrWriteAfterClose
 		return 0, aw.err
 	}
-	var n int
-	n, aw.err = aw.w.Write(b)
-	if aw.err != nil {
+	if aw.unknown != nil {
+		var n int
+		if aw.spool != nil {
+			n, aw.err = aw.spool.Write(b)
+		} else {
+			n, aw.err = aw.w.Write(b)
+		}
+		aw.written += int64(n)
 		return n, aw.err
 	}
+	overwrite := false
+	if int64(len(b)) > aw.nb {
+		b = b[0:aw.nb]
+		overwrite = true
+	}
+	var n int
+	n, aw.err = aw.w.Write(b)
 	aw.nb -= int64(n)
+	if aw.err == nil && overwrite {
+		return n, ErrWriteTooLong
+	}
 	return n, aw.err
 }
 
+// CopyFrom copies the current entry of r into the archive byte for byte,
+// writing its original header rather than re-encoding it.
+// This preserves details which a Header does not capture, such as field order,
+// name termination, non-canonical numbers, and a padding byte other than "\n", or its absence.
+// None of the entry's data may have been read from r before calling CopyFrom.
+// Members of thin archives cannot be copied, as their data is held in external files.
+func (aw *Writer) CopyFrom(r *Reader) error {
+	if aw.closed {
+		return ErrWriteAfterClose
+	}
+	if r.hdr != nil && r.hdr.Kind == KindThinReference {
+		return errCopyThin
+	}
+	if r.hdr == nil || r.nb != r.hdr.Size {
+		return errCopyAfterRead
+	}
+	if err := aw.CopyHeaderFrom(r, r.hdr.Size); err != nil {
+		return err
+	}
+	if _, err := io.Copy(aw, r); err != nil {
+		return err
+	}
+	if aw.pad {
+		padding := r.copyPadding()
+		aw.pad, aw.padding = len(padding) > 0, padding
+	}
+	return nil
+}
+
+// CopyHeaderFrom writes the original header of r's current entry, as CopyFrom does,
+// but with its size field set to size, and prepares to accept size bytes of new contents.
+// It may be called whether or not the entry's data has been read from r.
+func (aw *Writer) CopyHeaderFrom(r *Reader, size int64) error {
+	if aw.closed {
+		return ErrWriteAfterClose
+	}
+	if r.hdr == nil {
+		return errNoEntry
+	}
+	if r.hdr.Kind == KindThinReference {
+		return errCopyThin
+	}
+	if aw.err == nil {
+		aw.Flush()
+	}
+	if aw.err != nil {
+		return aw.err
+	}
+	raw := r.raw
+	if size != r.hdr.Size {
+		field, err := formatField("size", size+int64(len(r.bsdName)), sizeSize)
+		if err != nil {
+			return err
+		}
+		raw = append([]byte(nil), raw...)
+		offset := fileNameSize + modTimeSize + uidSize + gidSize + modeSize
+		copy(raw[offset:offset+sizeSize], pad(field, sizeSize))
+	}
+	if err := aw.beginEntry(r.hdr.Name); err != nil {
+		return err
+	}
+	if _, aw.err = aw.w.Write(raw); aw.err != nil {
+		return aw.err
+	}
+	if _, aw.err = aw.w.Write(r.bsdName); aw.err != nil {
+		return aw.err
+	}
+	aw.nb = size
+	aw.name = r.hdr.Name
+	aw.pad = (size+int64(len(r.bsdName)))%2 == 1
+	return nil
+}
+
 // Close closes the ar archive, flushing any unwritten
 // data to the underlying writer.
 func (aw *Writer) Close() error {
@@ -135,6 +393,16 @@ func (aw *Writer) Close() error {
 	}
 	aw.Flush()
 	aw.closed = true
+	if aw.out != nil {
+		aw.w = aw.out
+		for _, sp := range aw.pending {
+			if aw.err == nil {
+				_, aw.err = sp.WriteTo(aw.w)
+			}
+			sp.Close()
+		}
+		aw.pending = nil
+	}
 	if aw.err != nil {
 		return aw.err
 	}
@@ -6,11 +6,17 @@ package ar
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"testing/iotest"
+	"testing/quick"
 	"time"
 )
 
@@ -141,3 +147,360 @@ testLoop:
 		}
 	}
 }
+
+func TestWriteTooLong(t *testing.T) {
+	buf := new(bytes.Buffer)
+	tw := NewWriter(buf)
+	if err := tw.WriteHeader(&Header{Name: "small.txt", Size: 5}); err != nil {
+		t.Fatalf("Failed writing header: %v", err)
+	}
+	n, err := tw.Write([]byte("Kilts and sporrans"))
+	if err != ErrWriteTooLong {
+		t.Errorf("Write error = %v, want %v", err, ErrWriteTooLong)
+	}
+	if n != 5 {
+		t.Errorf("Write wrote %d bytes, want 5", n)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("`\nKilts\n")) {
+		t.Errorf("Excess bytes should not be written: %q", buf.Bytes())
+	}
+}
+
+func TestWriteTooShort(t *testing.T) {
+	tw := NewWriter(ioutil.Discard)
+	if err := tw.WriteHeader(&Header{Name: "small.txt", Size: 5}); err != nil {
+		t.Fatalf("Failed writing header: %v", err)
+	}
+	if _, err := tw.Write([]byte("Kil")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	err := tw.WriteHeader(&Header{Name: "small2.txt", Size: 11})
+	if !errors.Is(err, ErrWriteTooShort) {
+		t.Fatalf("WriteHeader error = %v, want %v", err, ErrWriteTooShort)
+	}
+	if !strings.Contains(err.Error(), "small.txt") {
+		t.Errorf("Error should name the short entry: %v", err)
+	}
+}
+
+// writeUnknownSizes writes the writerTests entries with UnknownSize headers.
+func writeUnknownSizes(t *testing.T, tw *Writer) {
+	tw.TerminateFilenamesSlash = true
+	for j, entry := range writerTests[0].entries {
+		hdr := *entry.header
+		hdr.Size = UnknownSize
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatalf("entry %d: Failed writing header: %v", j, err)
+		}
+		if _, err := io.WriteString(tw, entry.contents); err != nil {
+			t.Fatalf("entry %d: Failed writing contents: %v", j, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed closing archive: %v", err)
+	}
+}
+
+func TestWriterUnknownSizeSeeker(t *testing.T) {
+	expected, err := ioutil.ReadFile(writerTests[0].file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ioutil.TempFile("", "argo-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	writeUnknownSizes(t, NewWriter(f))
+	actual, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("Incorrect result: (-=expected, +=actual)\n%v", bytediff(expected, actual))
+	}
+}
+
+func TestWriterUnknownSizeSpool(t *testing.T) {
+	expected, err := ioutil.ReadFile(writerTests[0].file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, limit := range []int64{0, 4} { // 4 bytes forces a temp file
+		buf := new(bytes.Buffer)
+		tw := NewWriter(buf)
+		tw.SpoolLimit = limit
+		writeUnknownSizes(t, tw)
+		if actual := buf.Bytes(); !bytes.Equal(expected, actual) {
+			t.Errorf("limit %d: Incorrect result: (-=expected, +=actual)\n%v", limit, bytediff(expected, actual))
+		}
+	}
+}
+
+func TestCopyFrom(t *testing.T) {
+	// headers which re-encoding through Header would not reproduce
+	headers := []string{
+		"small.txt/      01405990895 1000  1001  0100664 5         `\n",
+		"small2.txt      1405990895  0     0     644     11        `\n",
+	}
+	for _, test := range []struct {
+		name string
+		src  string
+	}{
+		{"newline padding", ArFileHeader + headers[0] + "Kilts\n" + headers[1] + "Google.com\n\n"},
+		{"NUL padding", ArFileHeader + headers[0] + "Kilts\x00" + headers[1] + "Google.com\n\x00"},
+		{"other padding", ArFileHeader + headers[0] + "Kilts " + headers[1] + "Google.com\n "},
+		{"padding omitted", ArFileHeader + headers[0] + "Kilts" + headers[1] + "Google.com\n"},
+	} {
+		tr, err := NewReader(strings.NewReader(test.src))
+		if err != nil {
+			t.Fatalf("%s: NewReader: %v", test.name, err)
+		}
+		buf := new(bytes.Buffer)
+		tw := NewWriter(buf)
+		for {
+			if _, err := tr.Next(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: Next: %v", test.name, err)
+			}
+			if err := tw.CopyFrom(tr); err != nil {
+				t.Fatalf("%s: CopyFrom: %v", test.name, err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatalf("%s: Close: %v", test.name, err)
+		}
+		if actual := buf.Bytes(); !bytes.Equal([]byte(test.src), actual) {
+			t.Errorf("%s: Incorrect result: (-=expected, +=actual)\n%v", test.name, bytediff([]byte(test.src), actual))
+		}
+	}
+}
+
+func TestCopyFromThin(t *testing.T) {
+	src := thinFileHeader + "a.txt/          0           0     0     644     5         `\n"
+	tr, err := NewReader(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if hdr, err := tr.Next(); err != nil || hdr.Kind != KindThinReference {
+		t.Fatalf("Next = %+v, %v, want a thin reference", hdr, err)
+	}
+	tw := NewWriter(ioutil.Discard)
+	if err := tw.CopyFrom(tr); err != errCopyThin {
+		t.Errorf("CopyFrom = %v, want %v", err, errCopyThin)
+	}
+	if err := tw.CopyHeaderFrom(tr, 5); err != errCopyThin {
+		t.Errorf("CopyHeaderFrom = %v, want %v", err, errCopyThin)
+	}
+}
+
+func TestCopyFromAfterRead(t *testing.T) {
+	f, err := os.Open("testdata/common.ar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tr, err := NewReader(f)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	tw := NewWriter(ioutil.Discard)
+	if err := tw.CopyFrom(tr); err == nil {
+		t.Errorf("CopyFrom before Next should fail")
+	}
+	if _, err := tr.Next(); err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if _, err := tr.Read(make([]byte, 2)); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if err := tw.CopyFrom(tr); err == nil {
+		t.Errorf("CopyFrom after a partial read should fail")
+	}
+}
+
+func TestCopyHeaderFrom(t *testing.T) {
+	src := ArFileHeader + "small.txt/      01405990895 1000  1001  0100664 5         `\n" + "Kilts\n" +
+		"#1/13           1405990895  0     0     644     16        `\n" + "long_name.txtabc"
+	want := ArFileHeader + "small.txt/      01405990895 1000  1001  0100664 3         `\n" + "new\n" +
+		"#1/13           1405990895  0     0     644     17        `\n" + "long_name.txtnew!\n"
+	tr, err := NewReader(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	tw := NewWriter(buf)
+	for _, body := range []string{"new", "new!"} {
+		if _, err := tr.Next(); err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if _, err := ioutil.ReadAll(tr); err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		if err := tw.CopyHeaderFrom(tr, int64(len(body))); err != nil {
+			t.Fatalf("CopyHeaderFrom: %v", err)
+		}
+		io.WriteString(tw, body)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if actual := buf.Bytes(); !bytes.Equal([]byte(want), actual) {
+		t.Errorf("Incorrect result: (-=expected, +=actual)\n%v", bytediff([]byte(want), actual))
+	}
+}
+
+// quickHeader generates Headers whose fields fit the ar header widths.
+type quickHeader Header
+
+func (quickHeader) Generate(r *rand.Rand, size int) reflect.Value {
+	const nameChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789._-"
+	name := make([]byte, 1+r.Intn(fileNameSize-1))
+	for i := range name {
+		name[i] = nameChars[r.Intn(len(nameChars))]
+	}
+	h := quickHeader{
+		Name:    string(name),
+		ModTime: time.Unix(r.Int63n(999999999999), 0),
+		Uid:     r.Intn(999999),
+		Gid:     r.Intn(999999),
+		Mode:    100000 + int64(r.Intn(8))*100 + int64(r.Intn(8))*10 + int64(r.Intn(8)),
+		Size:    r.Int63n(9999999999),
+	}
+	return reflect.ValueOf(h)
+}
+
+func TestHeaderEncodingRoundTrip(t *testing.T) {
+	roundTrip := func(qh quickHeader, slash bool) bool {
+		hdr := Header(qh)
+		b, err := encodeHeader(&hdr, slash)
+		if err != nil {
+			t.Logf("encodeHeader(%+v): %v", hdr, err)
+			return false
+		}
+		if len(b) != headerSize {
+			t.Logf("encodeHeader(%+v) is %d bytes", hdr, len(b))
+			return false
+		}
+		tr, err := NewReader(bytes.NewReader(append([]byte(ArFileHeader), b...)))
+		if err != nil {
+			return false
+		}
+		got, err := tr.Next()
+		if err != nil {
+			t.Logf("Next(%q): %v", b, err)
+			return false
+		}
+		if !got.ModTime.Equal(hdr.ModTime) {
+			return false
+		}
+		got.ModTime = hdr.ModTime
+		return reflect.DeepEqual(*got, hdr)
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHeaderEncodingErrors(t *testing.T) {
+	tests := []struct {
+		hdr   Header
+		field string
+		err   error
+	}{
+		{Header{Name: "a_name_too_long_for_ar"}, "name", ErrFieldTooLong},
+		{Header{Name: "a", ModTime: time.Unix(-1, 0)}, "modification time", ErrFieldNegative},
+		{Header{Name: "a", ModTime: time.Unix(1000000000000, 0)}, "modification time", ErrFieldTooLong},
+		{Header{Name: "a", Uid: -1}, "uid", ErrFieldNegative},
+		{Header{Name: "a", Gid: 1000000}, "gid", ErrFieldTooLong},
+		{Header{Name: "a", Mode: 123456789}, "mode", ErrFieldTooLong},
+		{Header{Name: "a", Mode: 493}, "mode", ErrFieldNotOctal},
+		{Header{Name: "a", Size: 10000000000}, "size", ErrFieldTooLong},
+		{Header{Name: "a", Size: -2}, "size", ErrFieldNegative},
+	}
+	for i, test := range tests {
+		_, err := encodeHeader(&test.hdr, false)
+		var ferr *FieldError
+		if !errors.As(err, &ferr) || ferr.Field != test.field || !errors.Is(err, test.err) {
+			t.Errorf("test %d: encodeHeader error = %v, want %s %v", i, err, test.field, test.err)
+		}
+	}
+}
+
+func TestHeaderModeNotation(t *testing.T) {
+	// Mode holds octal digits, not permission bits
+	for _, test := range []struct {
+		mode int64
+		want string
+	}{
+		{644, "100644"},
+		{100644, "100644"},
+		{0644, "100420"},
+		{100755, "100755"},
+	} {
+		b, err := encodeHeader(&Header{Name: "a", Mode: test.mode}, false)
+		if err != nil {
+			t.Errorf("Mode %d: %v", test.mode, err)
+			continue
+		}
+		if got := strings.TrimRight(string(b[40:48]), " "); got != test.want {
+			t.Errorf("Mode %d: mode field %q, want %q", test.mode, got, test.want)
+		}
+	}
+}
+
+func TestFileInfoHeaderEncoding(t *testing.T) {
+	f, err := ioutil.TempFile("", "argo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	for _, perm := range []os.FileMode{0755, 0644, 0600, 0777} {
+		if err := f.Chmod(perm); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hdr, err := FileInfoHeader(fi)
+		if err != nil {
+			t.Fatalf("FileInfoHeader: %v", err)
+		}
+		b, err := hdr.Encode(FormatCommon)
+		if err != nil {
+			t.Errorf("%o: Encode: %v", perm, err)
+			continue
+		}
+		if got, want := string(b[40:48]), fmt.Sprintf("100%o", perm); strings.TrimRight(got, " ") != want {
+			t.Errorf("%o: mode field = %q, want %q", perm, got, want)
+		}
+		archive := ArFileHeader + string(b)
+		if problems := Verify(strings.NewReader(archive), int64(len(archive))); len(problems) != 0 {
+			t.Errorf("%o: Verify: %v", perm, problems)
+		}
+		var decoded Header
+		if err := decoded.UnmarshalBinary(b); err != nil {
+			t.Fatalf("%o: UnmarshalBinary: %v", perm, err)
+		}
+		if got := decoded.FileInfo().Mode(); got != perm {
+			t.Errorf("%o: decoded mode = %o", perm, got)
+		}
+	}
+}
+
+func TestHeaderUidGidOrder(t *testing.T) {
+	b, err := encodeHeader(&Header{Name: "a", Uid: 1000, Gid: 50, Size: 1}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b[28:40]), "1000  50    "; got != want {
+		t.Errorf("uid and gid fields = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+
+	"github.com/laher/argo/apt"
+)
+
+func runIndex(args []string) error {
+	fs := flag.NewFlagSet("index", flag.ContinueOnError)
+	contents := fs.Bool("contents", false, "also write Contents-<arch>.gz indexes")
+	cacheFile := fs.String("cache", "", "cache `file` for Contents, to avoid rereading unchanged packages")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	pool := "."
+	switch len(args) {
+	case 1:
+	case 2:
+		pool = args[1]
+	default:
+		return errArgs
+	}
+	repo := args[0]
+	pkgs, err := apt.ScanPackages(repo, pool)
+	if err != nil {
+		return err
+	}
+	var extra []apt.IndexFile
+	if *contents || *cacheFile != "" {
+		cache := &apt.ContentsCache{}
+		if *cacheFile != "" {
+			if cache, err = apt.LoadContentsCache(*cacheFile); err != nil {
+				return err
+			}
+		}
+		if extra, err = apt.WriteContentsIndexes(repo, repo, pkgs, cache); err != nil {
+			return err
+		}
+		if *cacheFile != "" {
+			if err := cache.Save(*cacheFile); err != nil {
+				return err
+			}
+		}
+	}
+	return apt.WriteIndexes(repo, pkgs, nil, extra...)
+}
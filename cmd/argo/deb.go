@@ -0,0 +1,259 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/laher/argo/deb"
+)
+
+var errArgs = errors.New("wrong number of arguments")
+
+func runContents(args []string) error {
+	if len(args) != 1 {
+		return errArgs
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(os.Stdout)
+	if err := deb.ListContents(w, bufio.NewReader(f)); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func runExtract(args []string) error {
+	if len(args) != 2 {
+		return errArgs
+	}
+	return extract(args[0], args[1], "")
+}
+
+func runControl(args []string) error {
+	dir := "DEBIAN"
+	switch len(args) {
+	case 1:
+	case 2:
+		dir = args[1]
+	default:
+		return errArgs
+	}
+	return extract(args[0], "", dir)
+}
+
+func extract(pkg, dataDir, controlDir string) error {
+	f, err := os.Open(pkg)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return deb.Extract(bufio.NewReader(f), dataDir, controlDir, &deb.ExtractOptions{Chown: true})
+}
+
+func runInfo(args []string) error {
+	if len(args) != 1 {
+		return errArgs
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := deb.ReadInfo(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	age := "new"
+	if info.Version+"\n" == deb.OldBinaryVersion {
+		age = "old"
+	}
+	fmt.Printf(" %s Debian package, version %s.\n", age, info.Version)
+	if fi, err := f.Stat(); err == nil {
+		fmt.Printf(" size %d bytes: %d members.\n", fi.Size(), len(info.Members))
+	}
+	for _, m := range info.Members {
+		size := "?" // the data size of an old-format package read as a stream
+		if m.Size >= 0 {
+			size = strconv.FormatInt(m.Size, 10)
+		}
+		fmt.Printf(" %9s bytes  %s", size, m.Name)
+		if m.Tarball {
+			fmt.Printf(" (%s)", m.Compression)
+		}
+		fmt.Println()
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(info.Control.String(), "\n"), "\n") {
+		fmt.Println("", line)
+	}
+	return nil
+}
+
+func runField(args []string) error {
+	if len(args) < 1 {
+		return errArgs
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	control, err := deb.ReadControl(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	names := args[1:]
+	if len(names) == 0 {
+		fmt.Print(control)
+		return nil
+	}
+	if len(names) == 1 {
+		if value, ok := control.Get(names[0]); ok {
+			fmt.Println(value)
+		}
+		return nil
+	}
+	for _, name := range names {
+		for _, field := range control {
+			if strings.EqualFold(field.Name, name) {
+				fmt.Print(deb.Paragraph{field})
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// fieldEdits collects repeated -set Field=Value flags.
+type fieldEdits []deb.ControlField
+
+func (e *fieldEdits) String() string { return fmt.Sprint(*e) }
+
+func (e *fieldEdits) Set(s string) error {
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return fmt.Errorf("%q is not Field=Value", s)
+	}
+	*e = append(*e, deb.ControlField{Name: s[:i], Value: s[i+1:]})
+	return nil
+}
+
+// fieldNames collects repeated -delete Field flags.
+type fieldNames []string
+
+func (n *fieldNames) String() string { return strings.Join(*n, ",") }
+
+func (n *fieldNames) Set(s string) error {
+	*n = append(*n, s)
+	return nil
+}
+
+func runRepack(args []string) error {
+	fs := flag.NewFlagSet("repack", flag.ContinueOnError)
+	var sets fieldEdits
+	var deletes fieldNames
+	fs.Var(&sets, "set", "set a control field, as `Field=Value` (repeatable)")
+	fs.Var(&deletes, "delete", "delete a control `field` (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) != 2 {
+		return errArgs
+	}
+	in, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(out)
+	err = deb.Repack(bw, bufio.NewReader(in), func(control *deb.Paragraph) error {
+		for _, name := range deletes {
+			control.Delete(name)
+		}
+		for _, f := range sets {
+			control.Set(f.Name, f.Value)
+		}
+		return nil
+	})
+	if err == nil {
+		err = bw.Flush()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(args[1])
+	}
+	return err
+}
+
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "write findings as one JSON object per package, per line")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errArgs
+	}
+	failed := 0
+	for _, name := range fs.Args() {
+		findings, err := lintFile(name)
+		if err != nil {
+			return err
+		}
+		for _, f := range findings {
+			if f.Severity == deb.SeverityError {
+				failed++
+				break
+			}
+		}
+		if *asJSON {
+			if findings == nil {
+				findings = []deb.Finding{}
+			}
+			b, err := json.Marshal(struct {
+				Package  string        `json:"package"`
+				Findings []deb.Finding `json:"findings"`
+			}{name, findings})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\n", b)
+			continue
+		}
+		for _, f := range findings {
+			fmt.Printf("%s: %s\n", name, f)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d packages have errors", failed, fs.NArg())
+	}
+	return nil
+}
+
+func lintFile(name string) ([]deb.Finding, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return deb.Lint(bufio.NewReader(f), &deb.LintOptions{Ipk: strings.HasSuffix(name, ".ipk")})
+}
@@ -0,0 +1,77 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command argo inspects ar archives and Debian packages.
+//
+// Usage:
+//
+//	argo <command> [arguments]
+//
+// The commands are:
+//
+//	contents <package.deb>            list the data tarball, like 'dpkg-deb --contents'
+//	extract <package.deb> <dir>       unpack the data tarball into dir, like 'dpkg-deb --extract'
+//	control <package.deb> [<dir>]     unpack the control tarball into dir (default DEBIAN), like 'dpkg-deb --control'
+//	info <package.deb>                summarise the members and show the control file, like 'dpkg-deb --info'
+//	field <package.deb> [<field>...]  show control fields, like 'dpkg-deb --field'
+//	lint [-json] <package.deb>...     check package structure and policy, failing if any package has errors;
+//	                                  .ipk files are checked as opkg packages
+//	repack [-set <Field=Value>]... [-delete <field>]... <in.deb> <out.deb>
+//	                                  copy a package with control fields changed, keeping its data tarball intact
+//	index [-contents] [-cache <file>] <repo> [<pool>]
+//	                                  write Packages, Packages.gz and Release (and optionally Contents-<arch>.gz)
+//	                                  for the .debs below pool (default repo)
+//
+// When run as root, extract and control apply the owners recorded in the package.
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"contents": {"<package.deb>", runContents},
+	"extract":  {"<package.deb> <dir>", runExtract},
+	"control":  {"<package.deb> [<dir>]", runControl},
+	"info":     {"<package.deb>", runInfo},
+	"field":    {"<package.deb> [<field>...]", runField},
+	"index":    {"[-contents] [-cache <file>] <repo> [<pool>]", runIndex},
+	"lint":     {"[-json] <package.deb>...", runLint},
+	"repack":   {"[-set <Field=Value>]... [-delete <field>]... <in.deb> <out.deb>", runRepack},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "argo: unknown command %q\n", os.Args[1])
+		usage()
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "argo %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: argo <command> [arguments]")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "\targo %s %s\n", name, commands[name].usage)
+	}
+	os.Exit(2)
+}
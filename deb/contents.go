@@ -0,0 +1,163 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WalkFunc is called for each entry of a tarball walked by WalkData.
+// r reads the entry's contents, and is only valid until WalkFunc returns.
+// If WalkFunc returns an error, the walk stops and returns that error.
+type WalkFunc func(hdr *tar.Header, r io.Reader) error
+
+// WalkData streams the package read from r, calling fn for each entry of its data tarball.
+// It returns ErrFormat if the package has no data tarball.
+func WalkData(r io.Reader, fn WalkFunc) error {
+	return walkTarball(r, IsData, fn)
+}
+
+// walkTarball calls fn for each entry of the first tarball member whose name matches.
+func walkTarball(r io.Reader, match func(name string) bool, fn WalkFunc) error {
+	dr, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	for {
+		hdr, err := dr.Next()
+		if err == io.EOF {
+			return ErrFormat
+		}
+		if err != nil {
+			return err
+		}
+		if !match(hdr.Name) {
+			continue
+		}
+		tr, err := dr.Tar()
+		if err != nil {
+			return err
+		}
+		for {
+			th, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := fn(th, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ListContents writes a listing of the data tarball of the package read from r,
+// in the format of 'dpkg-deb --contents'.
+func ListContents(w io.Writer, r io.Reader) error {
+	l := NewLister(w)
+	return WalkData(r, func(hdr *tar.Header, _ io.Reader) error {
+		return l.WriteEntry(hdr)
+	})
+}
+
+// A Lister writes tarball entries one per line, as listed by 'tar -tv' and hence 'dpkg-deb -c':
+//
+//	-rw-r--r-- root/root      1234 2014-07-22 10:01 ./usr/share/doc/hello/README
+//
+// As with tar, the owner and size column widens to fit, and stays widened for later entries.
+type Lister struct {
+	w     io.Writer
+	width int // width of the owner and size column
+}
+
+// NewLister creates a Lister writing to w.
+func NewLister(w io.Writer) *Lister {
+	return &Lister{w: w, width: 18}
+}
+
+// WriteEntry writes the listing line for hdr.
+func (l *Lister) WriteEntry(hdr *tar.Header) error {
+	owner := hdr.Uname
+	if owner == "" {
+		owner = strconv.Itoa(hdr.Uid)
+	}
+	group := hdr.Gname
+	if group == "" {
+		group = strconv.Itoa(hdr.Gid)
+	}
+	size := strconv.FormatInt(hdr.Size, 10)
+	switch hdr.Typeflag {
+	case tar.TypeChar, tar.TypeBlock:
+		size = fmt.Sprintf("%d,%d", hdr.Devmajor, hdr.Devminor)
+	}
+	pad := len(owner) + 1 + len(group) + len(size)
+	if pad > l.width {
+		l.width = pad
+	}
+	line := fmt.Sprintf("%s %s/%s %*s %s %s", modeString(hdr), owner, group,
+		l.width-pad+len(size), size, hdr.ModTime.Local().Format("2006-01-02 15:04"), hdr.Name)
+	switch hdr.Typeflag {
+	case tar.TypeSymlink:
+		line += " -> " + hdr.Linkname
+	case tar.TypeLink:
+		line += " link to " + hdr.Linkname
+	}
+	_, err := fmt.Fprintln(l.w, line)
+	return err
+}
+
+// modeString renders an entry's type and permissions, such as "drwxr-xr-x".
+func modeString(hdr *tar.Header) string {
+	b := []byte("?rwxrwxrwx")
+	switch hdr.Typeflag {
+	case tar.TypeReg, tar.TypeGNUSparse:
+		b[0] = '-'
+	case tar.TypeLink:
+		b[0] = 'h'
+	case tar.TypeSymlink:
+		b[0] = 'l'
+	case tar.TypeChar:
+		b[0] = 'c'
+	case tar.TypeBlock:
+		b[0] = 'b'
+	case tar.TypeDir:
+		b[0] = 'd'
+	case tar.TypeFifo:
+		b[0] = 'p'
+	case tar.TypeCont:
+		b[0] = 'C'
+	}
+	for i := 0; i < 9; i++ {
+		if hdr.Mode&(1<<uint(8-i)) == 0 {
+			b[i+1] = '-'
+		}
+	}
+	special := []struct {
+		bit   int64
+		index int
+		set   byte // shown when the corresponding execute bit is set too
+		unset byte
+	}{
+		{04000, 3, 's', 'S'},
+		{02000, 6, 's', 'S'},
+		{01000, 9, 't', 'T'},
+	}
+	for _, s := range special {
+		if hdr.Mode&s.bit == 0 {
+			continue
+		}
+		if b[s.index] == 'x' {
+			b[s.index] = s.set
+		} else {
+			b[s.index] = s.unset
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,106 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/laher/argo/ar"
+)
+
+func TestLister(t *testing.T) {
+	mtime := time.Unix(1405990895, 0)
+	date := mtime.Local().Format("2006-01-02 15:04")
+	tests := []struct {
+		hdr  tar.Header
+		want string
+	}{
+		{
+			tar.Header{Name: "./usr/", Typeflag: tar.TypeDir, Mode: 0755, Uname: "root", Gname: "root"},
+			"drwxr-xr-x root/root         0 " + date + " ./usr/",
+		},
+		{
+			tar.Header{Name: "./usr/bin/su", Typeflag: tar.TypeReg, Mode: 04755, Size: 1234, Uname: "root", Gname: "root"},
+			"-rwsr-xr-x root/root      1234 " + date + " ./usr/bin/su",
+		},
+		{
+			tar.Header{Name: "./tmp/", Typeflag: tar.TypeDir, Mode: 01777, Uname: "root", Gname: "root"},
+			"drwxrwxrwt root/root         0 " + date + " ./tmp/",
+		},
+		{
+			tar.Header{Name: "./usr/bin/sh", Typeflag: tar.TypeSymlink, Linkname: "dash", Mode: 0777, Uname: "root", Gname: "root"},
+			"lrwxrwxrwx root/root         0 " + date + " ./usr/bin/sh -> dash",
+		},
+		{
+			tar.Header{Name: "./usr/bin/ash", Typeflag: tar.TypeLink, Linkname: "./usr/bin/dash", Mode: 02644, Uname: "root", Gname: "root"},
+			"hrw-r-Sr-- root/root         0 " + date + " ./usr/bin/ash link to ./usr/bin/dash",
+		},
+		{
+			tar.Header{Name: "./dev/null", Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 3, Mode: 0666, Uname: "root", Gname: "root"},
+			"crw-rw-rw- root/root       1,3 " + date + " ./dev/null",
+		},
+		{
+			// no names, and too wide for the column, which widens
+			tar.Header{Name: "./var/big", Typeflag: tar.TypeReg, Mode: 0640, Size: 123456789, Uid: 12345, Gid: 54321},
+			"-rw-r----- 12345/54321 123456789 " + date + " ./var/big",
+		},
+		{
+			tar.Header{Name: "./run/fifo", Typeflag: tar.TypeFifo, Mode: 0600, Uname: "root", Gname: "root"},
+			"prw------- root/root           0 " + date + " ./run/fifo",
+		},
+	}
+	var buf bytes.Buffer
+	l := NewLister(&buf)
+	var want []string
+	for _, test := range tests {
+		test.hdr.ModTime = mtime
+		if err := l.WriteEntry(&test.hdr); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, test.want)
+	}
+	got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Errorf("line %d:\n got %q\nwant %q", i, got, want[i])
+		}
+	}
+}
+
+func TestListContents(t *testing.T) {
+	pkg := buildPackage(t, testFiles, nil)
+	var buf bytes.Buffer
+	if err := ListContents(&buf, bytes.NewReader(pkg)); err != nil {
+		t.Fatalf("ListContents: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(testFiles) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(testFiles), buf.String())
+	}
+	for i, f := range testFiles {
+		want := f.hdr.Name
+		if f.hdr.Linkname != "" {
+			want += " link to " + f.hdr.Linkname
+		}
+		if !strings.HasSuffix(lines[i], " "+want) {
+			t.Errorf("line %d = %q, want it to end with %q", i, lines[i], want)
+		}
+	}
+
+	// a package without a data tarball
+	var noData bytes.Buffer
+	aw := ar.NewWriter(&noData)
+	aw.WriteHeader(&ar.Header{Name: BinaryName, Mode: 644, Size: int64(len(BinaryVersion))})
+	io.WriteString(aw, BinaryVersion)
+	aw.Close()
+	if err := WalkData(&noData, func(*tar.Header, io.Reader) error { return nil }); err != ErrFormat {
+		t.Errorf("WalkData without a data tarball = %v, want %v", err, ErrFormat)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrNoControl shows that a package's control tarball has no control file
+	ErrNoControl = errors.New("deb: package has no control file")
+	// ErrNoField shows that a control paragraph lacks a requested field
+	ErrNoField = errors.New("deb: no such field")
+)
+
+// A ControlField is a single field of a control paragraph.
+// Multi-line values hold their continuation lines after a "\n", each with its leading space, as written.
+type ControlField struct {
+	Name  string
+	Value string
+}
+
+// A Paragraph is a control paragraph, such as a package's control file, with its fields in order.
+type Paragraph []ControlField
+
+// ParseParagraph parses the first paragraph of deb822 control data read from r.
+// Leading blank lines and comment lines are skipped.
+func ParseParagraph(r io.Reader) (Paragraph, error) {
+	var p Paragraph
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	line := 0
+	for s.Scan() {
+		line++
+		text := strings.TrimRight(s.Text(), "\r")
+		switch {
+		case strings.TrimSpace(text) == "":
+			if len(p) > 0 {
+				return p, nil
+			}
+		case text[0] == '#':
+		case text[0] == ' ' || text[0] == '\t':
+			if len(p) == 0 {
+				return nil, fmt.Errorf("deb: control line %d: continuation line before any field", line)
+			}
+			p[len(p)-1].Value += "\n" + text
+		default:
+			i := strings.IndexByte(text, ':')
+			if i <= 0 {
+				return nil, fmt.Errorf("deb: control line %d: %q is not a field", line, text)
+			}
+			p = append(p, ControlField{text[:i], strings.TrimSpace(text[i+1:])})
+		}
+	}
+	return p, s.Err()
+}
+
+// Get returns the value of the named field. Field names are case-insensitive.
+func (p Paragraph) Get(name string) (string, bool) {
+	for _, f := range p {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set sets the value of the named field, keeping its place if the paragraph already has it.
+// A new field goes before any Description, which conventionally ends the paragraph.
+func (p *Paragraph) Set(name, value string) {
+	for i, f := range *p {
+		if strings.EqualFold(f.Name, name) {
+			(*p)[i].Value = value
+			return
+		}
+	}
+	f := ControlField{name, value}
+	for i, g := range *p {
+		if strings.EqualFold(g.Name, "Description") {
+			*p = append((*p)[:i], append(Paragraph{f}, (*p)[i:]...)...)
+			return
+		}
+	}
+	*p = append(*p, f)
+}
+
+// Delete removes the named field, reporting whether the paragraph had it.
+func (p *Paragraph) Delete(name string) bool {
+	for i, f := range *p {
+		if strings.EqualFold(f.Name, name) {
+			*p = append((*p)[:i], (*p)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the paragraph in control file format, ending with a newline.
+func (p Paragraph) String() string {
+	var b bytes.Buffer
+	for _, f := range p {
+		b.WriteString(f.Name)
+		b.WriteString(":")
+		if f.Value != "" && f.Value[0] != '\n' {
+			b.WriteString(" ")
+		}
+		b.WriteString(f.Value)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// MemberInfo summarises one ar member of a package.
+type MemberInfo struct {
+	Name        string
+	Size        int64       // size in bytes, as recorded in the member's ar header
+	Tarball     bool        // whether the member is a control or data tarball
+	Compression Compression // compression of the tarball, if it is one
+}
+
+// Info summarises a package, much as 'dpkg-deb --info' does.
+type Info struct {
+	Version string       // format version, from the debian-binary member
+	Members []MemberInfo // ar members, in order
+	Control Paragraph    // the control file
+}
+
+// ReadInfo reads the package from r, summarising its members and parsing its control file.
+func ReadInfo(r io.Reader) (*Info, error) {
+	dr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{}
+	for {
+		hdr, err := dr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		m := MemberInfo{Name: hdr.Name, Size: hdr.Size}
+		m.Compression, m.Tarball = CompressionOf(hdr.Name)
+		info.Members = append(info.Members, m)
+		switch {
+		case hdr.Name == BinaryName:
+			b, err := ioutil.ReadAll(dr)
+			if err != nil {
+				return nil, err
+			}
+			info.Version = strings.TrimSpace(string(b))
+		case IsControl(hdr.Name) && info.Control == nil:
+			tr, err := dr.Tar()
+			if err != nil {
+				return nil, err
+			}
+			if info.Control, err = readControl(tr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if info.Control == nil {
+		return nil, ErrNoControl
+	}
+	return info, nil
+}
+
+// ReadControl reads the package from r as far as its control tarball, returning the parsed control file.
+func ReadControl(r io.Reader) (Paragraph, error) {
+	dr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		hdr, err := dr.Next()
+		if err == io.EOF {
+			return nil, ErrNoControl
+		}
+		if err != nil {
+			return nil, err
+		}
+		if IsControl(hdr.Name) {
+			tr, err := dr.Tar()
+			if err != nil {
+				return nil, err
+			}
+			return readControl(tr)
+		}
+	}
+}
+
+// readControl finds and parses the control file in a control tarball.
+func readControl(tr *tar.Reader) (Paragraph, error) {
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			return nil, ErrNoControl
+		}
+		if err != nil {
+			return nil, err
+		}
+		if controlPath(th.Name) == ControlName {
+			return ParseParagraph(tr)
+		}
+	}
+}
+
+// Field returns the value of a single control field of the package file at path,
+// as 'dpkg-deb --field' does. It returns ErrNoField if the package lacks the field.
+func Field(path, name string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	control, err := ReadControl(bufio.NewReader(f))
+	if err != nil {
+		return "", err
+	}
+	value, ok := control.Get(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNoField, name)
+	}
+	return value, nil
+}
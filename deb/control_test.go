@@ -0,0 +1,111 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseParagraph(t *testing.T) {
+	in := "\n# a comment\nPackage: hello\nVersion:1.0-1\r\nDescription: greeting\n greets the world\n .\n\tloudly\n\nPackage: second\n"
+	p, err := ParseParagraph(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Paragraph{
+		{"Package", "hello"},
+		{"Version", "1.0-1"},
+		{"Description", "greeting\n greets the world\n .\n\tloudly"},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("got %q, want %q", p, want)
+	}
+	if v, ok := p.Get("version"); !ok || v != "1.0-1" {
+		t.Errorf("Get(version) = %q, %v", v, ok)
+	}
+	if _, ok := p.Get("Depends"); ok {
+		t.Error("Get(Depends) found a missing field")
+	}
+	if got, want := p.String(), "Package: hello\nVersion: 1.0-1\nDescription: greeting\n greets the world\n .\n\tloudly\n"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+
+	for _, bad := range []string{" continued\n", "Package hello\n", ": empty name\n"} {
+		if _, err := ParseParagraph(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseParagraph(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestParagraphSetDelete(t *testing.T) {
+	p := Paragraph{{"Package", "hello"}, {"Description", "greeting"}}
+	p.Set("depends", "libc6")
+	p.Set("package", "hi")
+	p.Set("Homepage", "https://example.com")
+	if !p.Delete("DESCRIPTION") || p.Delete("Description") {
+		t.Error("Delete did not remove Description exactly once")
+	}
+	p.Set("Priority", "optional")
+	if got, want := p.String(), "Package: hi\ndepends: libc6\nHomepage: https://example.com\nPriority: optional\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadInfo(t *testing.T) {
+	pkg := buildPackage(t, testFiles, nil)
+	info, err := ReadInfo(bytes.NewReader(pkg))
+	if err != nil {
+		t.Fatalf("ReadInfo: %v", err)
+	}
+	if info.Version != "2.0" {
+		t.Errorf("Version = %q, want 2.0", info.Version)
+	}
+	names := []string{BinaryName, "control.tar.gz", "data.tar.gz"}
+	if len(info.Members) != len(names) {
+		t.Fatalf("got %d members, want %d", len(info.Members), len(names))
+	}
+	for i, m := range info.Members {
+		if m.Name != names[i] || m.Size <= 0 || m.Tarball != (i > 0) {
+			t.Errorf("member %d = %+v", i, m)
+		}
+		if m.Tarball && m.Compression != CompressionGzip {
+			t.Errorf("member %d compression = %v, want gzip", i, m.Compression)
+		}
+	}
+	if info.Members[0].Size != int64(len(BinaryVersion)) {
+		t.Errorf("debian-binary size = %d, want %d", info.Members[0].Size, len(BinaryVersion))
+	}
+	if got := info.Control.String(); got != testControl {
+		t.Errorf("control = %q, want %q", got, testControl)
+	}
+}
+
+func TestField(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deb-field")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hello.deb")
+	if err := ioutil.WriteFile(path, buildPackage(t, testFiles, nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Field(path, "Version"); err != nil || v != "1.0-1" {
+		t.Errorf("Field(Version) = %q, %v; want 1.0-1", v, err)
+	}
+	if v, err := Field(path, "description"); err != nil || v != "greeting\n greets the world" {
+		t.Errorf("Field(description) = %q, %v", v, err)
+	}
+	if _, err := Field(path, "Depends"); !errors.Is(err, ErrNoField) {
+		t.Errorf("Field(Depends) error = %v, want %v", err, ErrNoField)
+	}
+}
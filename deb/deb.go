@@ -0,0 +1,225 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package deb implements access to Debian binary packages (.deb files).
+// A .deb is an ar archive holding a 'debian-binary' version member, followed by
+// a control tarball and a data tarball. This package builds on argo's ar package
+// to read and write those members sequentially. Old-format (0.939) packages, which predate
+// the use of ar, can be read too.
+//
+// References:
+//
+//	http://man7.org/linux/man-pages/man5/deb.5.html
+//	http://man7.org/linux/man-pages/man5/deb-old.5.html
+//	https://www.debian.org/doc/debian-policy/ch-controlfields.html
+package deb
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	// BinaryName is the name of the member holding the package format version.
+	BinaryName = "debian-binary"
+	// BinaryVersion is the contents of the debian-binary member.
+	BinaryVersion = "2.0\n"
+	// OldBinaryVersion is the first line of an old-format (0.939) package, which is not an ar archive.
+	OldBinaryVersion = "0.939000\n"
+	// ControlPrefix is the name of the control tarball, minus its compression suffix.
+	ControlPrefix = "control.tar"
+	// DataPrefix is the name of the data tarball, minus its compression suffix.
+	DataPrefix = "data.tar"
+	// ControlName is the name of the control file inside the control tarball.
+	ControlName = "control"
+	// Md5sumsName is the name of the md5sums file inside the control tarball.
+	Md5sumsName = "md5sums"
+	// ConffilesName is the name of the conffiles file inside the control tarball.
+	ConffilesName = "conffiles"
+)
+
+var (
+	// ErrFormat describes a package which is not laid out as a .deb
+	ErrFormat = errors.New("deb: invalid package format")
+	// ErrUnsupportedCompression describes a tarball compressed with an unregistered algorithm
+	ErrUnsupportedCompression = errors.New("deb: unsupported compression")
+	// ErrNotTarball shows that a tar stream was requested for a member which is not a tarball
+	ErrNotTarball = errors.New("deb: member is not a tarball")
+)
+
+// Layout identifies how a package's members are packed together.
+type Layout int
+
+const (
+	// LayoutAr is an ar archive, as for a modern .deb or most .ipk files
+	LayoutAr Layout = iota
+	// LayoutOld is the old (0.939) .deb format, which predates ar. It can be read but not written.
+	LayoutOld
+	// LayoutTar is a gzipped tarball of the members, as some opkg tools build .ipk files
+	LayoutTar
+)
+
+func (l Layout) String() string {
+	switch l {
+	case LayoutAr:
+		return "ar"
+	case LayoutOld:
+		return "old"
+	case LayoutTar:
+		return "tar"
+	}
+	return "unknown"
+}
+
+// Compression identifies the algorithm used to compress a control or data tarball.
+type Compression int
+
+const (
+	// CompressionNone is an uncompressed tarball
+	CompressionNone Compression = iota
+	// CompressionGzip is a .gz tarball
+	CompressionGzip
+	// CompressionXz is a .xz tarball
+	CompressionXz
+	// CompressionBzip2 is a .bz2 tarball
+	CompressionBzip2
+	// CompressionLzma is a .lzma tarball
+	CompressionLzma
+	// CompressionZstd is a .zst tarball
+	CompressionZstd
+)
+
+var compressionSuffixes = []string{
+	CompressionNone:  "",
+	CompressionGzip:  ".gz",
+	CompressionXz:    ".xz",
+	CompressionBzip2: ".bz2",
+	CompressionLzma:  ".lzma",
+	CompressionZstd:  ".zst",
+}
+
+var compressionNames = []string{
+	CompressionNone:  "none",
+	CompressionGzip:  "gzip",
+	CompressionXz:    "xz",
+	CompressionBzip2: "bzip2",
+	CompressionLzma:  "lzma",
+	CompressionZstd:  "zstd",
+}
+
+// String returns the conventional name of the compression algorithm.
+func (c Compression) String() string {
+	if c < 0 || int(c) >= len(compressionNames) {
+		return "unknown"
+	}
+	return compressionNames[c]
+}
+
+// Suffix returns the file name suffix used for tarballs with this compression.
+func (c Compression) Suffix() string {
+	if c < 0 || int(c) >= len(compressionSuffixes) {
+		return ""
+	}
+	return compressionSuffixes[c]
+}
+
+// CompressionOf returns the compression implied by a member name's suffix.
+// ok is false for names which are not control or data tarballs.
+func CompressionOf(name string) (c Compression, ok bool) {
+	var rest string
+	switch {
+	case strings.HasPrefix(name, ControlPrefix):
+		rest = name[len(ControlPrefix):]
+	case strings.HasPrefix(name, DataPrefix):
+		rest = name[len(DataPrefix):]
+	default:
+		return CompressionNone, false
+	}
+	for i, suffix := range compressionSuffixes {
+		if rest == suffix {
+			return Compression(i), true
+		}
+	}
+	return CompressionNone, false
+}
+
+// IsControl reports whether name is the name of a control tarball.
+func IsControl(name string) bool {
+	_, ok := CompressionOf(name)
+	return ok && strings.HasPrefix(name, ControlPrefix)
+}
+
+// IsData reports whether name is the name of a data tarball.
+func IsData(name string) bool {
+	_, ok := CompressionOf(name)
+	return ok && strings.HasPrefix(name, DataPrefix)
+}
+
+// A Decompressor returns a reader of the uncompressed form of r.
+type Decompressor func(r io.Reader) (io.Reader, error)
+
+// A Compressor returns a writer which compresses onto w.
+// The returned writer must be closed to flush any buffered output.
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+var (
+	compMu        sync.RWMutex
+	decompressors = map[Compression]Decompressor{
+		CompressionNone:  func(r io.Reader) (io.Reader, error) { return r, nil },
+		CompressionGzip:  func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		CompressionBzip2: func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil },
+	}
+	compressors = map[Compression]Compressor{
+		CompressionNone: func(w io.Writer) (io.WriteCloser, error) { return nopCloser{w}, nil },
+		CompressionGzip: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+	}
+)
+
+// RegisterDecompressor registers a Decompressor for a compression algorithm.
+// The standard library only provides gzip and bzip2; callers needing xz or zstd
+// support should register an implementation here.
+func RegisterDecompressor(c Compression, d Decompressor) {
+	compMu.Lock()
+	decompressors[c] = d
+	compMu.Unlock()
+}
+
+// RegisterCompressor registers a Compressor for a compression algorithm.
+func RegisterCompressor(c Compression, comp Compressor) {
+	compMu.Lock()
+	compressors[c] = comp
+	compMu.Unlock()
+}
+
+// NewDecompressor returns a reader of the uncompressed contents of r.
+func NewDecompressor(c Compression, r io.Reader) (io.Reader, error) {
+	compMu.RLock()
+	d, ok := decompressors[c]
+	compMu.RUnlock()
+	if !ok {
+		return nil, ErrUnsupportedCompression
+	}
+	return d(r)
+}
+
+// NewCompressor returns a writer which compresses onto w.
+func NewCompressor(c Compression, w io.Writer) (io.WriteCloser, error) {
+	compMu.RLock()
+	comp, ok := compressors[c]
+	compMu.RUnlock()
+	if !ok {
+		return nil, ErrUnsupportedCompression
+	}
+	return comp(w)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
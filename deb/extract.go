@@ -0,0 +1,293 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxSymlinks is the number of symbolic links followed while resolving a path, before giving up.
+const maxSymlinks = 255
+
+var (
+	// ErrUnsupportedType shows that an entry could not be extracted, because of its type (e.g. a device node)
+	ErrUnsupportedType = errors.New("deb: unsupported entry type")
+	// ErrTooManyLinks shows that resolving a path within the target directory followed too many symbolic links
+	ErrTooManyLinks = errors.New("deb: too many levels of symbolic links")
+)
+
+// ExtractOptions controls how tarball entries are written to disk.
+type ExtractOptions struct {
+	// Chown applies the owners recorded in the tarball. It only takes effect when running as root.
+	Chown bool
+}
+
+// ExtractData unpacks the data tarball of the package read from r into dir, as 'dpkg-deb -x' does.
+func ExtractData(r io.Reader, dir string, opts *ExtractOptions) error {
+	return Extract(r, dir, "", opts)
+}
+
+// ExtractControl unpacks the control tarball of the package read from r into dir, as 'dpkg-deb -e' does.
+func ExtractControl(r io.Reader, dir string, opts *ExtractOptions) error {
+	return Extract(r, "", dir, opts)
+}
+
+// Extract unpacks the data tarball of the package read from r into dataDir,
+// and its control tarball into controlDir, in a single pass. Either directory may be
+// empty, to skip that tarball. The directories are created if necessary.
+//
+// Modes and modification times are preserved. Entries are confined to their directory:
+// paths are resolved as if the directory were the root of the filesystem, so neither
+// ".." components nor symbolic links, whether in the tarball or already on disk, can
+// lead outside it. Device nodes and FIFOs are only supported on Linux, and device nodes
+// need privileges to create.
+func Extract(r io.Reader, dataDir, controlDir string, opts *ExtractOptions) error {
+	if opts == nil {
+		opts = &ExtractOptions{}
+	}
+	dr, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	sawData, sawControl := false, false
+	for {
+		hdr, err := dr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var dir string
+		switch {
+		case IsData(hdr.Name):
+			dir, sawData = dataDir, true
+		case IsControl(hdr.Name):
+			dir, sawControl = controlDir, true
+		}
+		if dir == "" {
+			continue
+		}
+		tr, err := dr.Tar()
+		if err != nil {
+			return err
+		}
+		if err := newExtractor(dir, opts).extract(tr); err != nil {
+			return err
+		}
+	}
+	if dataDir != "" && !sawData || controlDir != "" && !sawControl {
+		return ErrFormat
+	}
+	return nil
+}
+
+// An extractor writes the entries of one tarball below root.
+type extractor struct {
+	root  string
+	chown bool
+	dirs  []dirAttr // directories whose mode and times are set once their contents are written
+}
+
+type dirAttr struct {
+	path  string
+	mode  os.FileMode
+	atime time.Time
+	mtime time.Time
+}
+
+func newExtractor(root string, opts *ExtractOptions) *extractor {
+	return &extractor{root: filepath.Clean(root), chown: opts.Chown && os.Geteuid() == 0}
+}
+
+func (x *extractor) extract(tr *tar.Reader) error {
+	if err := os.MkdirAll(x.root, 0755); err != nil {
+		return err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := x.entry(hdr, tr); err != nil {
+			return fmt.Errorf("deb: extracting %s: %w", hdr.Name, err)
+		}
+	}
+	// set directory modes and times last, as a read-only directory could not take its contents,
+	// and creating them changes its times
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		d := x.dirs[i]
+		if err := os.Chmod(d.path, d.mode); err != nil {
+			return err
+		}
+		if err := os.Chtimes(d.path, d.atime, d.mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) entry(hdr *tar.Header, r io.Reader) error {
+	target, err := x.resolve(hdr.Name)
+	if err != nil {
+		return err
+	}
+	if target == x.root && hdr.Typeflag != tar.TypeDir {
+		return ErrUnsupportedType
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	mode := hdr.FileInfo().Mode()
+	if hdr.Typeflag == tar.TypeDir {
+		fi, err := os.Lstat(target)
+		switch {
+		case os.IsNotExist(err):
+			err = os.Mkdir(target, 0700)
+		case err == nil && !fi.IsDir():
+			err = fmt.Errorf("%s exists and is not a directory", target)
+		}
+		if err != nil {
+			return err
+		}
+		x.dirs = append(x.dirs, dirAttr{target, mode, accessTime(hdr), hdr.ModTime})
+		if x.chown {
+			return os.Lchown(target, hdr.Uid, hdr.Gid)
+		}
+		return nil
+	}
+	if err := x.remove(target); err != nil {
+		return err
+	}
+	switch hdr.Typeflag {
+	case tar.TypeReg, tar.TypeGNUSparse:
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, r)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		// the link is stored as is; it is only ever followed within root, by resolve
+		if err := os.Symlink(hdr.Linkname, target); err != nil {
+			return err
+		}
+		if x.chown {
+			return os.Lchown(target, hdr.Uid, hdr.Gid)
+		}
+		return nil
+	case tar.TypeLink:
+		source, err := x.resolve(hdr.Linkname)
+		if err != nil {
+			return err
+		}
+		// the link shares the attributes of its target, which are already set
+		return os.Link(source, target)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		if err := mknod(target, hdr); err != nil {
+			return err
+		}
+	default:
+		return ErrUnsupportedType
+	}
+	if err := x.finish(target, hdr, mode); err != nil {
+		return err
+	}
+	return os.Chtimes(target, accessTime(hdr), hdr.ModTime)
+}
+
+// finish applies the ownership and mode of an entry.
+// Ownership comes first, as changing it clears any setuid and setgid bits.
+func (x *extractor) finish(target string, hdr *tar.Header, mode os.FileMode) error {
+	if x.chown {
+		if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+			return err
+		}
+	}
+	return os.Chmod(target, mode)
+}
+
+// remove clears the way for a new non-directory entry, replacing whatever file is there.
+func (x *extractor) remove(target string) error {
+	fi, err := os.Lstat(target)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	case fi.IsDir():
+		return fmt.Errorf("%s exists and is a directory", target)
+	}
+	return os.Remove(target)
+}
+
+// resolve maps a tarball path to a path below root, treating root as the root of the filesystem.
+// Symbolic links met along the way are followed within root, with absolute links
+// starting again at root, and ".." never rising above it. The final component is not followed.
+func (x *extractor) resolve(name string) (string, error) {
+	queue := strings.Split(cleanPath(name), "/")
+	cur := "" // the path resolved so far, relative to root
+	links := 0
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		switch p {
+		case "", ".":
+			continue
+		case "..":
+			if cur = path.Dir(cur); cur == "." {
+				cur = ""
+			}
+			continue
+		}
+		next := path.Join(cur, p)
+		if len(queue) == 0 {
+			cur = next
+			break
+		}
+		full := filepath.Join(x.root, filepath.FromSlash(next))
+		fi, err := os.Lstat(full)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			// missing components are created later, as directories
+			cur = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", ErrTooManyLinks
+		}
+		link, err := os.Readlink(full)
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(link) {
+			cur = ""
+		}
+		queue = append(strings.Split(link, "/"), queue...)
+	}
+	return filepath.Join(x.root, filepath.FromSlash(cur)), nil
+}
+
+func accessTime(hdr *tar.Header) time.Time {
+	if hdr.AccessTime.IsZero() {
+		return hdr.ModTime
+	}
+	return hdr.AccessTime
+}
@@ -0,0 +1,124 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExtract(t *testing.T) {
+	mtime := time.Unix(1405990895, 0)
+	pkg := buildPackage(t, []tarEntry{
+		{tar.Header{Name: "./usr/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: mtime}, ""},
+		{tar.Header{Name: "./usr/bin/", Typeflag: tar.TypeDir, Mode: 0750, ModTime: mtime}, ""},
+		{tar.Header{Name: "./usr/bin/hello", Typeflag: tar.TypeReg, Mode: 0755, ModTime: mtime}, "#!/bin/sh\necho hello\n"},
+		{tar.Header{Name: "./usr/bin/hi", Typeflag: tar.TypeSymlink, Linkname: "hello", Mode: 0777, ModTime: mtime}, ""},
+		{tar.Header{Name: "./usr/bin/hey", Typeflag: tar.TypeLink, Linkname: "./usr/bin/hello", ModTime: mtime}, ""},
+		{tar.Header{Name: "./etc/hello.conf", Typeflag: tar.TypeReg, Mode: 0600, ModTime: mtime}, "greeting=hello\n"},
+		// a read-only directory still takes its contents
+		{tar.Header{Name: "./usr/share/", Typeflag: tar.TypeDir, Mode: 0555, ModTime: mtime}, ""},
+		{tar.Header{Name: "./usr/share/doc/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: mtime}, ""},
+		{tar.Header{Name: "./usr/share/doc/README", Typeflag: tar.TypeReg, Mode: 0444, ModTime: mtime}, "hello\n"},
+	}, map[string]string{"postinst": "#!/bin/sh\n"})
+	dir, err := ioutil.TempDir("", "deb-extract")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Chmod(filepath.Join(dir, "root/usr/share"), 0755)
+	data, control := filepath.Join(dir, "root"), filepath.Join(dir, "DEBIAN")
+	if err := Extract(bytes.NewReader(pkg), data, control, nil); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	for name, want := range map[string]os.FileMode{
+		"usr/bin":              os.ModeDir | 0750,
+		"usr/bin/hello":        0755,
+		"usr/bin/hi":           os.ModeSymlink | 0777,
+		"etc/hello.conf":       0600,
+		"usr/share":            os.ModeDir | 0555,
+		"usr/share/doc":        os.ModeDir | 0755,
+		"usr/share/doc/README": 0444,
+		"../DEBIAN/control":    0644,
+		"../DEBIAN/postinst":   0755,
+	} {
+		fi, err := os.Lstat(filepath.Join(data, name))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if fi.Mode() != want {
+			t.Errorf("%s: mode %v, want %v", name, fi.Mode(), want)
+		}
+		if fi.Mode()&os.ModeSymlink == 0 && !fi.ModTime().Equal(mtime) {
+			t.Errorf("%s: modified %v, want %v", name, fi.ModTime(), mtime)
+		}
+	}
+	if link, err := os.Readlink(filepath.Join(data, "usr/bin/hi")); err != nil || link != "hello" {
+		t.Errorf("symlink = %q, %v; want \"hello\"", link, err)
+	}
+	hello, _ := os.Stat(filepath.Join(data, "usr/bin/hello"))
+	hey, err := os.Stat(filepath.Join(data, "usr/bin/hey"))
+	if err != nil || !os.SameFile(hello, hey) {
+		t.Errorf("hard link is not the same file as its target: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(control, "md5sums")); err != nil {
+		t.Errorf("control: %v", err)
+	}
+}
+
+func TestExtractConfined(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deb-extract")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root, outside := filepath.Join(dir, "root"), filepath.Join(dir, "outside")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// a link already on disk, pointing out of the target directory
+	if err := os.Symlink(outside, filepath.Join(root, "preexisting")); err != nil {
+		t.Fatal(err)
+	}
+
+	pkg := buildPackage(t, []tarEntry{
+		{tar.Header{Name: "../../dotdot", Typeflag: tar.TypeReg, Mode: 0644}, "x"},
+		{tar.Header{Name: "./abs", Typeflag: tar.TypeSymlink, Linkname: outside}, ""},
+		{tar.Header{Name: "./abs/via-abs", Typeflag: tar.TypeReg, Mode: 0644}, "x"},
+		{tar.Header{Name: "./rel", Typeflag: tar.TypeSymlink, Linkname: "../../../.."}, ""},
+		{tar.Header{Name: "./rel/via-rel", Typeflag: tar.TypeReg, Mode: 0644}, "x"},
+		{tar.Header{Name: "./preexisting/via-disk", Typeflag: tar.TypeReg, Mode: 0644}, "x"},
+	}, nil)
+	if err := ExtractData(bytes.NewReader(pkg), root, nil); err != nil {
+		t.Fatalf("ExtractData: %v", err)
+	}
+	files, _ := ioutil.ReadDir(outside)
+	for _, fi := range files {
+		t.Errorf("%s escaped the target directory", fi.Name())
+	}
+	for _, name := range []string{"dotdot", "via-rel", filepath.Join(outside, "via-abs"), filepath.Join(outside, "via-disk")} {
+		if _, err := os.Lstat(filepath.Join(root, name)); err != nil {
+			t.Errorf("%s was not confined to the target directory: %v", name, err)
+		}
+	}
+
+	// a hard link may not reach outside either
+	pkg = buildPackage(t, []tarEntry{
+		{tar.Header{Name: "./passwd", Typeflag: tar.TypeLink, Linkname: "../../../../../etc/passwd"}, ""},
+	}, nil)
+	if err := ExtractData(bytes.NewReader(pkg), root, nil); err == nil {
+		t.Errorf("hard link to a file outside the target directory was extracted")
+	}
+}
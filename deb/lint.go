@@ -0,0 +1,385 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Severity grades a lint Finding.
+type Severity int
+
+const (
+	// SeverityWarning is a departure from policy or convention which dpkg tolerates
+	SeverityWarning Severity = iota
+	// SeverityError is a defect which makes dpkg reject the package, or which breaks policy outright
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// MarshalText encodes the severity by name, so that findings encode readably as JSON.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Lint checks, as reported in Finding.Check.
+const (
+	CheckMemberOrder   = "member-order"   // the ar members are missing, misnamed or out of order
+	CheckBinaryVersion = "binary-version" // the debian-binary member holds an unsupported version
+	CheckCompression   = "compression"    // a tarball is compressed in a way dpkg or this package cannot read
+	CheckTarball       = "tarball"        // a tarball is corrupt
+	CheckRequiredField = "required-field" // the control file lacks a mandatory field
+	CheckPackageName   = "package-name"   // the Package field is not a valid package name
+	CheckVersion       = "version"        // the Version field does not parse
+	CheckRelation      = "relation"       // a relationship field does not parse
+	CheckMd5sums       = "md5sums"        // md5sums is missing, or does not match the data tarball
+	CheckUnsafePath    = "unsafe-path"    // a data tarball path is absolute or contains ".."
+	CheckWorldWritable = "world-writable" // a file or directory (other than a sticky one) is writable by anyone
+	CheckSetuid        = "setuid"         // a file is setuid or setgid
+	CheckInstalledSize = "installed-size" // Installed-Size is missing or inaccurate
+)
+
+// installedSizeMargin is the discrepancy in Installed-Size, in KiB, which is always tolerated.
+const installedSizeMargin = 4
+
+// A Finding is a single problem reported by Lint.
+type Finding struct {
+	Check    string   `json:"check"`          // one of the Check constants
+	Severity Severity `json:"severity"`       // encoded as "error" or "warning"
+	Path     string   `json:"path,omitempty"` // the ar member, control field or data path concerned, if any
+	Message  string   `json:"message"`
+}
+
+func (f Finding) String() string {
+	if f.Path != "" {
+		return fmt.Sprintf("%s: %s: %s: %s", f.Severity, f.Check, f.Path, f.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Check, f.Message)
+}
+
+// relationFields are the control fields checked by ParseRelations.
+var relationFields = []string{"Depends", "Pre-Depends", "Recommends", "Suggests", "Enhances", "Breaks", "Conflicts", "Provides", "Replaces"}
+
+// dpkgCompressions lists, for the control and data tarballs, the compressions which dpkg can unpack.
+var dpkgCompressions = map[string][]Compression{
+	ControlPrefix: {CompressionNone, CompressionGzip, CompressionXz, CompressionZstd},
+	DataPrefix:    {CompressionNone, CompressionGzip, CompressionXz, CompressionBzip2, CompressionLzma, CompressionZstd},
+}
+
+// LintOptions adjusts the checks made by Lint.
+type LintOptions struct {
+	// Ipk lints the package as an opkg .ipk rather than a .deb. opkg reads the members in any order,
+	// so only the position of debian-binary is checked. An ipk in the tarball layout is always linted as one.
+	Ipk bool
+}
+
+// Lint reads the package from r, running structural and policy checks much as a small lintian would,
+// and returns its findings in the order found. Checks of a tarball's contents are skipped,
+// with a CheckCompression warning, if no Decompressor is registered for its compression.
+// A nil opts lints the package as a .deb.
+// An error is returned only if the package cannot be read as an ar archive at all.
+func Lint(r io.Reader, opts *LintOptions) ([]Finding, error) {
+	if opts == nil {
+		opts = &LintOptions{}
+	}
+	dr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	l := &linter{conffiles: make(map[string]bool), have: make(map[string]string)}
+	// dpkg expects debian-binary, control.tar and data.tar in that order;
+	// members whose names start with "_" may come in between, and anything after data.tar is ignored.
+	ordered := !opts.Ipk && dr.Layout() != LayoutTar
+	first, control, data := true, "", ""
+	for {
+		hdr, err := dr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return l.findings, err
+		}
+		name := hdr.Name
+		switch {
+		case first:
+			first = false
+			if name != BinaryName {
+				l.errorf(CheckMemberOrder, name, "first member is %q, not %q", name, BinaryName)
+				return l.findings, nil
+			}
+			b, err := ioutil.ReadAll(io.LimitReader(dr, 64))
+			if err != nil {
+				return l.findings, err
+			}
+			l.checkBinaryVersion(string(b))
+		case IsControl(name) && control == "":
+			control = name
+			if l.checkCompression(name, ControlPrefix) {
+				l.lintControl(dr, name)
+			}
+		case IsData(name) && data == "":
+			data = name
+			if ordered && control == "" {
+				l.errorf(CheckMemberOrder, name, "precedes the control tarball, which dpkg requires first")
+			}
+			if l.checkCompression(name, DataPrefix) {
+				l.lintData(dr, name)
+			}
+		case IsControl(name) || IsData(name):
+			l.errorf(CheckMemberOrder, name, "duplicate tarball")
+		case ordered && data != "" && control != "":
+			l.warnf(CheckMemberOrder, name, "member after %s is ignored by dpkg", data)
+		case strings.HasPrefix(name, "_"):
+		case control == "" && strings.HasPrefix(name, ControlPrefix),
+			data == "" && strings.HasPrefix(name, DataPrefix):
+			l.errorf(CheckCompression, name, "unknown compression suffix")
+		default:
+			l.errorf(CheckMemberOrder, name, "unexpected member")
+		}
+	}
+	if control == "" {
+		l.errorf(CheckMemberOrder, "", "package has no %s member", ControlPrefix)
+	}
+	if data == "" {
+		l.errorf(CheckMemberOrder, "", "package has no %s member", DataPrefix)
+	}
+	l.checkControl()
+	return l.findings, nil
+}
+
+type linter struct {
+	findings      []Finding
+	control       Paragraph
+	sums          map[string]string // md5sums, if the control tarball has one
+	conffiles     map[string]bool
+	have          map[string]string // checksums of the data tarball's regular files
+	installedSize int64             // size of the data tarball's contents in KiB, computed as dpkg-gencontrol does
+	dataRead      bool              // whether the data tarball was read in full
+	controlRead   bool              // whether the control tarball was read in full
+}
+
+func (l *linter) add(check string, sev Severity, path, format string, args ...interface{}) {
+	l.findings = append(l.findings, Finding{check, sev, path, fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) errorf(check, path, format string, args ...interface{}) {
+	l.add(check, SeverityError, path, format, args...)
+}
+
+func (l *linter) warnf(check, path, format string, args ...interface{}) {
+	l.add(check, SeverityWarning, path, format, args...)
+}
+
+func (l *linter) checkBinaryVersion(v string) {
+	v = strings.TrimSuffix(v, "\n")
+	switch {
+	case v == strings.TrimSpace(OldBinaryVersion):
+		l.warnf(CheckBinaryVersion, BinaryName, "obsolete old format %s", v)
+	case !strings.HasPrefix(v, "2."):
+		l.errorf(CheckBinaryVersion, BinaryName, "unsupported format version %q", v)
+	case v != strings.TrimSpace(BinaryVersion):
+		l.warnf(CheckBinaryVersion, BinaryName, "format version %q is not %q", v, strings.TrimSpace(BinaryVersion))
+	}
+}
+
+// checkCompression checks that dpkg can unpack the tarball,
+// and reports whether its contents can be inspected here.
+func (l *linter) checkCompression(name, prefix string) bool {
+	c, _ := CompressionOf(name)
+	supported := false
+	for _, s := range dpkgCompressions[prefix] {
+		supported = supported || s == c
+	}
+	if !supported {
+		l.errorf(CheckCompression, name, "dpkg does not support %s compression for %s", c, prefix)
+	}
+	compMu.RLock()
+	_, ok := decompressors[c]
+	compMu.RUnlock()
+	if !ok {
+		l.warnf(CheckCompression, name, "contents not checked: no decompressor registered for %s", c)
+	}
+	return ok
+}
+
+func (l *linter) lintControl(dr *Reader, name string) {
+	tr, err := dr.Tar()
+	if err != nil {
+		l.errorf(CheckTarball, name, "%v", err)
+		return
+	}
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			l.controlRead = true
+			return
+		}
+		if err != nil {
+			l.errorf(CheckTarball, name, "%v", err)
+			return
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			l.errorf(CheckTarball, name, "%v", err)
+			return
+		}
+		switch controlPath(th.Name) {
+		case ControlName:
+			if l.control, err = ParseParagraph(bytes.NewReader(b)); err != nil {
+				l.errorf(CheckTarball, name, "%v", err)
+			}
+		case Md5sumsName:
+			if l.sums, err = ParseMd5sums(bytes.NewReader(b)); err != nil {
+				l.errorf(CheckMd5sums, name, "%v", err)
+				l.sums = make(map[string]string)
+			}
+		case ConffilesName:
+			for _, conffile := range parseConffiles(b) {
+				l.conffiles[conffile] = true
+			}
+		}
+	}
+}
+
+func (l *linter) lintData(dr *Reader, name string) {
+	tr, err := dr.Tar()
+	if err != nil {
+		l.errorf(CheckTarball, name, "%v", err)
+		return
+	}
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			l.dataRead = true
+			return
+		}
+		if err != nil {
+			l.errorf(CheckTarball, name, "%v", err)
+			return
+		}
+		if isUnsafePath(th.Name) {
+			l.errorf(CheckUnsafePath, th.Name, "path escapes the installation root")
+		}
+		if isUnsafePath(th.Linkname) && th.Typeflag == tar.TypeLink {
+			l.errorf(CheckUnsafePath, th.Name, "hard link target %q escapes the installation root", th.Linkname)
+		}
+		mode := th.Mode
+		switch {
+		case th.Typeflag == tar.TypeSymlink:
+		case mode&0002 != 0 && !(th.Typeflag == tar.TypeDir && mode&01000 != 0):
+			l.errorf(CheckWorldWritable, th.Name, "mode %04o is world-writable", mode&07777)
+		}
+		if mode&06000 != 0 && th.Typeflag == tar.TypeReg {
+			l.warnf(CheckSetuid, th.Name, "mode %04o is setuid or setgid", mode&07777)
+		}
+		switch th.Typeflag {
+		case tar.TypeReg:
+			l.installedSize += (th.Size + 1023) / 1024
+		case tar.TypeSymlink:
+			l.installedSize += (int64(len(th.Linkname)) + 1023) / 1024
+		case tar.TypeLink:
+		default:
+			l.installedSize++
+		}
+		if err := sumEntry(th, tr, l.have); err != nil {
+			l.errorf(CheckTarball, name, "%v", err)
+			return
+		}
+	}
+}
+
+// isUnsafePath reports whether a tarball path is absolute or has a ".." component.
+func isUnsafePath(name string) bool {
+	if strings.HasPrefix(name, "/") {
+		return true
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// checkControl runs the checks which need both the control file and the data tarball.
+func (l *linter) checkControl() {
+	if !l.controlRead {
+		return
+	}
+	if l.control == nil {
+		l.errorf(CheckRequiredField, ControlName, "control tarball has no control file")
+		return
+	}
+	for _, field := range []string{"Package", "Version", "Architecture", "Maintainer", "Description"} {
+		if v, ok := l.control.Get(field); !ok || v == "" {
+			l.errorf(CheckRequiredField, field, "missing")
+		}
+	}
+	if name, ok := l.control.Get("Package"); ok && name != "" && (len(name) < 2 || !isPackageName(name)) {
+		l.errorf(CheckPackageName, "Package", "%q is not a valid package name", name)
+	}
+	if v, ok := l.control.Get("Version"); ok && v != "" {
+		if _, err := ParseVersion(v); err != nil {
+			l.errorf(CheckVersion, "Version", "%v", err)
+		}
+	}
+	for _, field := range relationFields {
+		if v, ok := l.control.Get(field); ok {
+			if _, err := ParseRelations(v); err != nil {
+				l.errorf(CheckRelation, field, "%v", err)
+			}
+		}
+	}
+	if !l.dataRead {
+		return
+	}
+	if l.sums == nil {
+		l.warnf(CheckMd5sums, "", "control tarball has no %s file", Md5sumsName)
+	} else {
+		for _, p := range compareSums(l.sums, l.have, l.conffiles) {
+			sev := SeverityError
+			if p.Kind == SumExtra {
+				sev = SeverityWarning
+			}
+			l.add(CheckMd5sums, sev, p.Path, "%s", strings.TrimPrefix(p.String(), p.Path+": "))
+		}
+	}
+	l.checkInstalledSize()
+}
+
+// checkInstalledSize compares Installed-Size with the size dpkg-gencontrol would compute:
+// each regular file or symlink rounded up to a KiB (hard links counted once), and a KiB for anything else.
+// As dpkg-gencontrol's figure may also count the build tree's control directory, small differences are tolerated.
+func (l *linter) checkInstalledSize() {
+	v, ok := l.control.Get("Installed-Size")
+	if !ok {
+		l.warnf(CheckInstalledSize, "Installed-Size", "missing; the contents need %d KiB", l.installedSize)
+		return
+	}
+	declared, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || declared < 0 {
+		l.errorf(CheckInstalledSize, "Installed-Size", "%q is not a size in KiB", v)
+		return
+	}
+	margin := l.installedSize / 10
+	if margin < installedSizeMargin {
+		margin = installedSizeMargin
+	}
+	if diff := declared - l.installedSize; diff > margin || -diff > margin {
+		l.warnf(CheckInstalledSize, "Installed-Size", "%d KiB declared, but the contents need %d KiB", declared, l.installedSize)
+	}
+}
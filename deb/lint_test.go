@@ -0,0 +1,157 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/laher/argo/ar"
+)
+
+// lintResults summarises findings as "severity check path" strings.
+func lintResults(t *testing.T, pkg []byte) []string {
+	return lintResultsOptions(t, pkg, nil)
+}
+
+func lintResultsOptions(t *testing.T, pkg []byte, opts *LintOptions) []string {
+	findings, err := Lint(bytes.NewReader(pkg), opts)
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	var got []string
+	for _, f := range findings {
+		got = append(got, strings.TrimSpace(f.Severity.String()+" "+f.Check+" "+f.Path))
+	}
+	return got
+}
+
+// repack returns pkg with its control fields set, or deleted where the value is empty.
+func repack(t *testing.T, pkg []byte, fields ...string) []byte {
+	var out bytes.Buffer
+	err := Repack(&out, bytes.NewReader(pkg), func(control *Paragraph) error {
+		for i := 0; i < len(fields); i += 2 {
+			if fields[i+1] == "" {
+				control.Delete(fields[i])
+			} else {
+				control.Set(fields[i], fields[i+1])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Repack: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestLintClean(t *testing.T) {
+	pkg := buildPackage(t, []tarEntry{
+		{tar.Header{Name: "./", Mode: 0755, Typeflag: tar.TypeDir}, ""},
+		{tar.Header{Name: "./tmp/", Mode: 01777, Typeflag: tar.TypeDir}, ""},
+		{tar.Header{Name: "./tmp/hello", Mode: 0755, Typeflag: tar.TypeReg}, "#!/bin/sh\necho hello\n"},
+		{tar.Header{Name: "./tmp/hi", Linkname: "hello", Typeflag: tar.TypeSymlink}, ""},
+	}, nil)
+	if got := lintResults(t, pkg); !reflect.DeepEqual(got, []string{"warning installed-size Installed-Size"}) {
+		t.Errorf("findings = %q", got)
+	}
+	if got := lintResults(t, repack(t, pkg, "Installed-Size", "4", "Depends", "libc6 (>= 2.14) | busybox")); got != nil {
+		t.Errorf("findings = %q", got)
+	}
+}
+
+func TestLintPolicy(t *testing.T) {
+	pkg := buildPackage(t, []tarEntry{
+		{tar.Header{Name: "/etc/abs", Mode: 0644, Typeflag: tar.TypeReg}, "x"},
+		{tar.Header{Name: "./usr/../../escape", Mode: 0644, Typeflag: tar.TypeReg}, "x"},
+		{tar.Header{Name: "./var/shared", Mode: 0666, Typeflag: tar.TypeReg}, "x"},
+		{tar.Header{Name: "./usr/bin/su", Mode: 04755, Typeflag: tar.TypeReg}, "x"},
+	}, nil)
+	pkg = repack(t, pkg, "Package", "Hello", "Version", "v1", "Maintainer", "", "Depends", "foo (>= 1", "Installed-Size", "100")
+	want := []string{
+		"error unsafe-path /etc/abs",
+		"error unsafe-path ./usr/../../escape",
+		"error world-writable ./var/shared",
+		"warning setuid ./usr/bin/su",
+		"error required-field Maintainer",
+		"error package-name Package",
+		"error version Version",
+		"error relation Depends",
+		"warning installed-size Installed-Size",
+	}
+	if got := lintResults(t, pkg); !reflect.DeepEqual(got, want) {
+		t.Errorf("findings =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestLintStructure(t *testing.T) {
+	var buf bytes.Buffer
+	aw := ar.NewWriter(&buf)
+	for _, m := range []struct{ name, body string }{
+		{BinaryName, "2.1\n"},
+		{"_gpgbuilder", "signature"},
+		{"control.tar.lzma", "not really lzma"},
+		{"junk", ""},
+	} {
+		aw.WriteHeader(&ar.Header{Name: m.name, Mode: 644, Size: int64(len(m.body))})
+		aw.Write([]byte(m.body))
+	}
+	aw.Close()
+	want := []string{
+		"warning binary-version debian-binary",
+		"error compression control.tar.lzma",
+		"warning compression control.tar.lzma",
+		"error member-order junk",
+		"error member-order",
+	}
+	if got := lintResults(t, buf.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("findings =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestFindingJSON(t *testing.T) {
+	b, err := json.Marshal(Finding{CheckSetuid, SeverityWarning, "./usr/bin/su", "mode 4755 is setuid or setgid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"check":"setuid","severity":"warning","path":"./usr/bin/su","message":"mode 4755 is setuid or setgid"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestLintMemberOrder(t *testing.T) {
+	pkg := repack(t, buildPackage(t, testFiles, nil), "Installed-Size", "5")
+	_, bodies := readMembers(t, pkg)
+	// members in the order opkg-build writes them
+	order := []struct{ name, body string }{{BinaryName, bodies[0]}, {"data.tar.gz", bodies[2]}, {"control.tar.gz", bodies[1]}}
+
+	var arIpk bytes.Buffer
+	aw := ar.NewWriter(&arIpk)
+	for _, m := range order {
+		aw.WriteHeader(&ar.Header{Name: m.name, Mode: 644, Size: int64(len(m.body))})
+		aw.Write([]byte(m.body))
+	}
+	aw.Close()
+	want := []string{"error member-order data.tar.gz"}
+	if got := lintResults(t, arIpk.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("as a deb, ar layout findings = %q, want %q", got, want)
+	}
+	if got := lintResultsOptions(t, arIpk.Bytes(), &LintOptions{Ipk: true}); got != nil {
+		t.Errorf("as an ipk, ar layout findings = %q", got)
+	}
+
+	var tarIpk bytes.Buffer
+	if err := writeTarMembers(&tarIpk, []member{{order[0].name, []byte(order[0].body)}, {order[1].name, []byte(order[1].body)}, {order[2].name, []byte(order[2].body)}}, time.Unix(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if got := lintResults(t, tarIpk.Bytes()); got != nil {
+		t.Errorf("tar layout findings = %q", got)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// ErrNoMd5sums shows that a package's control tarball has no md5sums file
+var ErrNoMd5sums = errors.New("deb: package has no md5sums file")
+
+// SumProblemKind classifies a discrepancy between md5sums and data.tar.
+type SumProblemKind int
+
+const (
+	// SumMismatch is a file whose contents do not match its recorded checksum
+	SumMismatch SumProblemKind = iota
+	// SumMissing is a file listed in md5sums but absent from data.tar
+	SumMissing
+	// SumExtra is a regular file in data.tar which md5sums does not list.
+	// Conffiles are exempt, as dpkg does not record their checksums in md5sums.
+	SumExtra
+)
+
+func (k SumProblemKind) String() string {
+	switch k {
+	case SumMismatch:
+		return "mismatch"
+	case SumMissing:
+		return "missing"
+	case SumExtra:
+		return "extra"
+	}
+	return "unknown"
+}
+
+// A SumProblem describes a single file failing verification.
+type SumProblem struct {
+	Path string // path relative to the package root, as written in md5sums
+	Kind SumProblemKind
+	Want string // checksum recorded in md5sums (empty for SumExtra)
+	Have string // checksum of data.tar contents (empty for SumMissing)
+}
+
+func (p SumProblem) String() string {
+	switch p.Kind {
+	case SumMismatch:
+		return fmt.Sprintf("%s: checksum mismatch (md5sums %s, data %s)", p.Path, p.Want, p.Have)
+	case SumMissing:
+		return fmt.Sprintf("%s: listed in md5sums but missing from data", p.Path)
+	case SumExtra:
+		return fmt.Sprintf("%s: not listed in md5sums", p.Path)
+	}
+	return p.Path
+}
+
+// ParseMd5sums parses the contents of an md5sums control file into a map of path to checksum.
+// Paths are normalised to be relative, without a leading "./".
+func ParseMd5sums(r io.Reader) (map[string]string, error) {
+	sums := make(map[string]string)
+	s := bufio.NewScanner(r)
+	line := 0
+	for s.Scan() {
+		line++
+		text := s.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if len(text) < md5.Size*2+2 || text[md5.Size*2] != ' ' {
+			return nil, fmt.Errorf("deb: malformed md5sums line %d", line)
+		}
+		sum := strings.ToLower(text[:md5.Size*2])
+		name := strings.TrimLeft(text[md5.Size*2:], " *")
+		sums[cleanPath(name)] = sum
+	}
+	return sums, s.Err()
+}
+
+// VerifyMd5sums reads a package sequentially, reading md5sums from the control tarball
+// and checksumming every regular file in the data tarball, much like debsums.
+// It returns every discrepancy found, sorted by path. An empty result means the package verified cleanly.
+func VerifyMd5sums(r io.Reader) ([]SumProblem, error) {
+	dr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		sums      map[string]string
+		conffiles = make(map[string]bool)
+		have      = make(map[string]string)
+	)
+	for {
+		hdr, err := dr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case IsControl(hdr.Name):
+			tr, err := dr.Tar()
+			if err != nil {
+				return nil, err
+			}
+			if sums, err = readControlSums(tr, conffiles); err != nil {
+				return nil, err
+			}
+		case IsData(hdr.Name):
+			tr, err := dr.Tar()
+			if err != nil {
+				return nil, err
+			}
+			if err = sumData(tr, have); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if sums == nil {
+		return nil, ErrNoMd5sums
+	}
+	return compareSums(sums, have, conffiles), nil
+}
+
+// compareSums compares the checksums recorded in md5sums with those of the data tarball's files,
+// returning the discrepancies sorted by path.
+func compareSums(sums, have map[string]string, conffiles map[string]bool) []SumProblem {
+	var problems []SumProblem
+	for name, want := range sums {
+		got, ok := have[name]
+		switch {
+		case !ok:
+			problems = append(problems, SumProblem{Path: name, Kind: SumMissing, Want: want})
+		case got != want:
+			problems = append(problems, SumProblem{Path: name, Kind: SumMismatch, Want: want, Have: got})
+		}
+	}
+	for name, got := range have {
+		if _, ok := sums[name]; !ok && !conffiles[name] {
+			problems = append(problems, SumProblem{Path: name, Kind: SumExtra, Have: got})
+		}
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Path < problems[j].Path })
+	return problems
+}
+
+// readControlSums reads md5sums from a control tarball, recording any conffiles as it goes.
+func readControlSums(tr *tar.Reader, conffiles map[string]bool) (map[string]string, error) {
+	var sums map[string]string
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			return sums, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch controlPath(th.Name) {
+		case Md5sumsName:
+			if sums, err = ParseMd5sums(tr); err != nil {
+				return nil, err
+			}
+		case ConffilesName:
+			b, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range parseConffiles(b) {
+				conffiles[name] = true
+			}
+		}
+	}
+}
+
+// parseConffiles parses the contents of a conffiles control file into a list of paths,
+// normalised as ParseMd5sums does.
+func parseConffiles(b []byte) []string {
+	var names []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, cleanPath(line))
+		}
+	}
+	return names
+}
+
+// sumData checksums each regular file in a data tarball.
+func sumData(tr *tar.Reader, have map[string]string) error {
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := sumEntry(th, tr, have); err != nil {
+			return err
+		}
+	}
+}
+
+// sumEntry checksums the data tarball entry th, just returned by tr.Next, if it is a regular file.
+// Hard links take the checksum of their target.
+func sumEntry(th *tar.Header, tr *tar.Reader, have map[string]string) error {
+	switch th.Typeflag {
+	case tar.TypeReg:
+		h := md5.New()
+		if _, err := io.Copy(h, tr); err != nil {
+			return err
+		}
+		have[cleanPath(th.Name)] = fmt.Sprintf("%x", h.Sum(nil))
+	case tar.TypeLink:
+		if sum, ok := have[cleanPath(th.Linkname)]; ok {
+			have[cleanPath(th.Name)] = sum
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMd5sums(t *testing.T) {
+	in := "d41d8cd98f00b204e9800998ecf8427e  usr/bin/a\n" +
+		"D41D8CD98F00B204E9800998ECF8427E *./usr/bin/b\n\n"
+	sums, err := ParseMd5sums(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("ParseMd5sums: %v", err)
+	}
+	want := map[string]string{
+		"usr/bin/a": "d41d8cd98f00b204e9800998ecf8427e",
+		"usr/bin/b": "d41d8cd98f00b204e9800998ecf8427e",
+	}
+	if !reflect.DeepEqual(sums, want) {
+		t.Errorf("ParseMd5sums = %v, want %v", sums, want)
+	}
+	if _, err := ParseMd5sums(strings.NewReader("nonsense\n")); err == nil {
+		t.Errorf("Expected error for malformed md5sums")
+	}
+}
+
+func TestVerifyMd5sums(t *testing.T) {
+	pkg := buildPackage(t, testFiles, map[string]string{"conffiles": "/etc/hello.conf\n"})
+	problems, err := VerifyMd5sums(bytes.NewReader(pkg))
+	if err != nil {
+		t.Fatalf("VerifyMd5sums: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Unexpected problems in generated package: %v", problems)
+	}
+}
+
+func TestVerifyMd5sumsProblems(t *testing.T) {
+	md5sums := "00000000000000000000000000000000  usr/bin/hello\n" +
+		"d41d8cd98f00b204e9800998ecf8427e  usr/bin/gone\n" +
+		"d41d8cd98f00b204e9800998ecf8427e  ./usr/bin/hi\n"
+	pkg := buildPackage(t, testFiles, map[string]string{"md5sums": md5sums})
+	problems, err := VerifyMd5sums(bytes.NewReader(pkg))
+	if err != nil {
+		t.Fatalf("VerifyMd5sums: %v", err)
+	}
+	want := []struct {
+		path string
+		kind SumProblemKind
+	}{
+		{"etc/hello.conf", SumExtra},
+		{"usr/bin/gone", SumMissing},
+		{"usr/bin/hello", SumMismatch},
+		{"usr/bin/hi", SumMismatch},
+		{"usr/share/doc/hello/README", SumExtra},
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("Got %d problems, want %d: %v", len(problems), len(want), problems)
+	}
+	for i, w := range want {
+		if problems[i].Path != w.path || problems[i].Kind != w.kind {
+			t.Errorf("problem %d = %v (%v), want %s (%v)", i, problems[i], problems[i].Kind, w.path, w.kind)
+		}
+	}
+}
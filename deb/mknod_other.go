@@ -0,0 +1,16 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package deb
+
+import (
+	"archive/tar"
+)
+
+// mknod creates a device node or FIFO for hdr. This is only supported on Linux.
+func mknod(path string, hdr *tar.Header) error {
+	return ErrUnsupportedType
+}
@@ -0,0 +1,266 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/laher/argo/ar"
+)
+
+// ErrNotAr shows that an operation needing an ar archive was attempted on a package of another layout
+var ErrNotAr = errors.New("deb: operation needs a package which is an ar archive")
+
+// A Reader provides sequential access to the members of a Debian package.
+// The Next method advances to the next member (including the first),
+// and then it can be treated as an io.Reader to access the member's raw data.
+// Tar returns the decompressed contents of control and data members.
+//
+// Packages laid out other than as an ar archive are presented as if they were:
+// an old-format (0.939) package has members debian-binary (holding OldBinaryVersion),
+// control.tar.gz and data.tar.gz, the last of Size ar.UnknownSize unless r is an io.Seeker;
+// an ipk which is a gzipped tarball has the members of the tarball.
+// Member names are given without any "./" prefix or trailing "/", as some ipk builders write them.
+type Reader struct {
+	ar     *ar.Reader
+	hdr    *ar.Header
+	layout Layout
+	alt    memberReader // members of a package which is not an ar archive
+}
+
+// A memberReader reads the members of a package which is not an ar archive.
+// Read reads the current member.
+type memberReader interface {
+	io.Reader
+	next() (*ar.Header, error)
+}
+
+// oldReader reads the members of an old-format package.
+type oldReader struct {
+	members []*ar.Header // members not yet reached
+	bodies  []io.Reader  // contents of each member not yet reached
+	cur     io.Reader    // remaining contents of the current member
+}
+
+// tarReader reads the members of an ipk which is a gzipped tarball.
+type tarReader struct {
+	tr *tar.Reader
+}
+
+// gzipMagic starts a gzip stream.
+const gzipMagic = "\x1f\x8b"
+
+// NewReader creates a new Reader reading a package from r, detecting its Layout.
+func NewReader(r io.Reader) (*Reader, error) {
+	magic := make([]byte, len(ar.ArFileHeader))
+	n, err := io.ReadFull(r, magic)
+	if err == nil && string(magic) == OldBinaryVersion[:len(magic)] {
+		return newOldReader(r)
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	// give back the magic, seeking where possible so that ar can still seek past members
+	if sr, ok := r.(io.Seeker); ok && err == nil {
+		if _, err := sr.Seek(-int64(n), io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	} else {
+		r = io.MultiReader(bytes.NewReader(magic[:n]), r)
+	}
+	if strings.HasPrefix(string(magic[:n]), gzipMagic) {
+		z, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &Reader{layout: LayoutTar, alt: &tarReader{tar.NewReader(z)}}, nil
+	}
+	arr, err := ar.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{ar: arr, layout: LayoutAr}, nil
+}
+
+// newOldReader reads the rest of an old-format package's header, after the first 8 bytes of OldBinaryVersion:
+// its closing newline, then the control tarball's size in decimal on a line of its own.
+func newOldReader(r io.Reader) (*Reader, error) {
+	if rest, err := readLine(r, 0); err != nil || rest != "" {
+		return nil, ErrFormat
+	}
+	line, err := readLine(r, 20)
+	if err != nil {
+		return nil, ErrFormat
+	}
+	controlSize, err := strconv.ParseInt(line, 10, 64)
+	if err != nil || controlSize < 0 {
+		return nil, ErrFormat
+	}
+	dataSize := int64(ar.UnknownSize)
+	if sr, ok := r.(io.Seeker); ok {
+		if here, err := sr.Seek(0, io.SeekCurrent); err == nil {
+			if end, err := sr.Seek(0, io.SeekEnd); err == nil && end >= here+controlSize {
+				dataSize = end - here - controlSize
+			}
+			if _, err := sr.Seek(here, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+	}
+	gz := CompressionGzip.Suffix()
+	old := &oldReader{
+		members: []*ar.Header{
+			{Name: BinaryName, Size: int64(len(OldBinaryVersion))},
+			{Name: ControlPrefix + gz, Size: controlSize},
+			{Name: DataPrefix + gz, Size: dataSize},
+		},
+		bodies: []io.Reader{
+			strings.NewReader(OldBinaryVersion),
+			io.LimitReader(r, controlSize),
+			r,
+		},
+	}
+	return &Reader{layout: LayoutOld, alt: old}, nil
+}
+
+// readLine reads a line of at most max bytes, plus its newline, a byte at a time so as not to read beyond it.
+func readLine(r io.Reader, max int) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return "", err
+		}
+		if b[0] == '\n' {
+			return string(line), nil
+		}
+		if len(line) == max {
+			return "", ErrFormat
+		}
+		line = append(line, b[0])
+	}
+}
+
+// Layout returns the layout of the package, as detected by NewReader.
+func (dr *Reader) Layout() Layout {
+	return dr.layout
+}
+
+// Next advances to the next member of the package.
+func (dr *Reader) Next() (*ar.Header, error) {
+	var hdr *ar.Header
+	var err error
+	if dr.alt != nil {
+		hdr, err = dr.alt.next()
+	} else if hdr, err = dr.ar.Next(); hdr == nil && err == nil {
+		err = ErrFormat
+	}
+	if hdr != nil && strings.HasPrefix(hdr.Name, "./") {
+		h := *hdr
+		h.Name = strings.TrimPrefix(h.Name, "./")
+		hdr = &h
+	}
+	dr.hdr = hdr
+	return hdr, err
+}
+
+func (old *oldReader) next() (*ar.Header, error) {
+	if len(old.members) == 0 {
+		old.cur = nil
+		return nil, io.EOF
+	}
+	if old.cur != nil {
+		if _, err := io.Copy(ioutil.Discard, old.cur); err != nil {
+			return nil, err
+		}
+	}
+	hdr := old.members[0]
+	old.cur = old.bodies[0]
+	old.members, old.bodies = old.members[1:], old.bodies[1:]
+	return hdr, nil
+}
+
+func (old *oldReader) Read(b []byte) (int, error) {
+	if old.cur == nil {
+		return 0, io.EOF
+	}
+	return old.cur.Read(b)
+}
+
+// next returns the tarball's next regular file as a member, skipping directories such as "./".
+func (t *tarReader) next() (*ar.Header, error) {
+	for {
+		th, err := t.tr.Next()
+		if err != nil {
+			return nil, err
+		}
+		switch th.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+		default:
+			return nil, ErrFormat
+		}
+		// an ar header's mode holds octal digits, as FileInfoHeader writes them
+		mode, _ := strconv.ParseInt(strconv.FormatInt(0100000|th.Mode&07777, 8), 10, 64)
+		return &ar.Header{
+			Name:    strings.TrimSuffix(path.Clean(th.Name), "/"),
+			ModTime: th.ModTime,
+			Uid:     th.Uid,
+			Gid:     th.Gid,
+			Mode:    mode,
+			Size:    th.Size,
+		}, nil
+	}
+}
+
+func (t *tarReader) Read(b []byte) (int, error) {
+	return t.tr.Read(b)
+}
+
+// Read reads the raw (still compressed) data of the current member.
+func (dr *Reader) Read(b []byte) (int, error) {
+	if dr.alt != nil {
+		return dr.alt.Read(b)
+	}
+	return dr.ar.Read(b)
+}
+
+// Tar returns a tar.Reader over the decompressed contents of the current member,
+// which must be a control or data tarball.
+func (dr *Reader) Tar() (*tar.Reader, error) {
+	if dr.hdr == nil {
+		return nil, ErrNotTarball
+	}
+	c, ok := CompressionOf(dr.hdr.Name)
+	if !ok {
+		return nil, ErrNotTarball
+	}
+	z, err := NewDecompressor(c, dr)
+	if err != nil {
+		return nil, err
+	}
+	return tar.NewReader(z), nil
+}
+
+// cleanPath normalises a tarball or md5sums path to the form used in md5sums files,
+// i.e. relative, without any leading "./" or "/".
+func cleanPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// controlPath normalises the path of a control tarball entry, as cleanPath does.
+// Old-format packages may hold their control files below DEBIAN/, which is dropped.
+func controlPath(name string) string {
+	return strings.TrimPrefix(cleanPath(name), "DEBIAN/")
+}
@@ -0,0 +1,227 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/laher/argo/ar"
+)
+
+// oldFormat rewrites a package built by Writer in the old (0.939) format.
+func oldFormat(t *testing.T, pkg []byte) []byte {
+	_, bodies := readMembers(t, pkg)
+	control, data := bodies[1], bodies[2]
+	return []byte(OldBinaryVersion + strconv.Itoa(len(control)) + "\n" + control + data)
+}
+
+// stream hides any io.Seeker implementation of r.
+type stream struct{ io.Reader }
+
+func TestOldFormat(t *testing.T) {
+	pkg := buildPackage(t, testFiles, nil)
+	old := oldFormat(t, pkg)
+	_, bodies := readMembers(t, pkg)
+
+	for _, test := range []struct {
+		r        io.Reader
+		dataSize int64
+	}{
+		{bytes.NewReader(old), int64(len(bodies[2]))},
+		{stream{bytes.NewReader(old)}, ar.UnknownSize},
+	} {
+		dr, err := NewReader(test.r)
+		if err != nil {
+			t.Fatalf("NewReader: %v", err)
+		}
+		if dr.Layout() != LayoutOld {
+			t.Errorf("Layout = %v, want %v", dr.Layout(), LayoutOld)
+		}
+		want := []struct {
+			name string
+			size int64
+			body string
+		}{
+			{BinaryName, int64(len(OldBinaryVersion)), OldBinaryVersion},
+			{"control.tar.gz", int64(len(bodies[1])), bodies[1]},
+			{"data.tar.gz", test.dataSize, bodies[2]},
+		}
+		for _, w := range want {
+			hdr, err := dr.Next()
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			if hdr.Name != w.name || hdr.Size != w.size {
+				t.Errorf("member %s of size %d, want %s of size %d", hdr.Name, hdr.Size, w.name, w.size)
+			}
+			if b, err := ioutil.ReadAll(dr); err != nil || string(b) != w.body {
+				t.Errorf("%s: read %d bytes, %v", w.name, len(b), err)
+			}
+		}
+		if _, err := dr.Next(); err != io.EOF {
+			t.Errorf("Next after data = %v, want EOF", err)
+		}
+	}
+
+	control, err := ReadControl(stream{bytes.NewReader(old)})
+	if err != nil || control.String() != testControl {
+		t.Errorf("ReadControl = %q, %v", control, err)
+	}
+	var modern, ancient bytes.Buffer
+	if err := ListContents(&modern, stream{bytes.NewReader(pkg)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ListContents(&ancient, bytes.NewReader(old)); err != nil {
+		t.Fatal(err)
+	}
+	if modern.String() != ancient.String() {
+		t.Errorf("contents differ:\n%s\nwant\n%s", ancient.String(), modern.String())
+	}
+	if err := Repack(ioutil.Discard, bytes.NewReader(old), func(*Paragraph) error { return nil }); err != ErrNotAr {
+		t.Errorf("Repack = %v, want %v", err, ErrNotAr)
+	}
+	for _, bad := range []string{"0.939000", "0.939000\n", "0.939000\nxyz\n", "0.939000x\n12\n", "!<arch"} {
+		if _, err := NewReader(bytes.NewReader([]byte(bad))); err == nil {
+			t.Errorf("NewReader(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestOldFormatDebianDir(t *testing.T) {
+	// old-format control tarballs may hold their files below DEBIAN/
+	pkg := buildPackage(t, testFiles, nil)
+	_, bodies := readMembers(t, pkg)
+	cr, err := NewDecompressor(CompressionGzip, strings.NewReader(bodies[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var control bytes.Buffer
+	cw, err := NewCompressor(CompressionGzip, &control)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, tw := tar.NewReader(cr), tar.NewWriter(cw)
+	tw.WriteHeader(&tar.Header{Name: "DEBIAN/", Typeflag: tar.TypeDir, Mode: 0755})
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		th.Name = "DEBIAN/" + cleanPath(th.Name)
+		tw.WriteHeader(th)
+		io.Copy(tw, tr)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cw.Close()
+	old := []byte(OldBinaryVersion + strconv.Itoa(control.Len()) + "\n" + control.String() + bodies[2])
+
+	if c, err := ReadControl(bytes.NewReader(old)); err != nil || c.String() != testControl {
+		t.Errorf("ReadControl = %q, %v", c, err)
+	}
+	if problems, err := VerifyMd5sums(bytes.NewReader(old)); err != nil || len(problems) != 0 {
+		t.Errorf("VerifyMd5sums = %v, %v", problems, err)
+	}
+}
+
+func TestIpk(t *testing.T) {
+	pkg := buildPackage(t, testFiles, nil)
+	headers, bodies := readMembers(t, pkg)
+	var want bytes.Buffer
+	if err := ListContents(&want, bytes.NewReader(pkg)); err != nil {
+		t.Fatal(err)
+	}
+
+	// ar layouts, with member names as various ipk builders write them
+	var layouts [][]byte
+	for _, v := range []struct {
+		prefix string
+		slash  bool
+	}{{"./", false}, {"", true}} {
+		var buf bytes.Buffer
+		aw := ar.NewWriter(&buf)
+		aw.TerminateFilenamesSlash = v.slash
+		for i, name := range []string{BinaryName, "control.tar.gz", "data.tar.gz"} {
+			aw.WriteHeader(&ar.Header{Name: v.prefix + name, Mode: 644, Size: int64(len(bodies[i]))})
+			io.WriteString(aw, bodies[i])
+		}
+		aw.Close()
+		layouts = append(layouts, buf.Bytes())
+	}
+	if len(headers) != 3 {
+		t.Fatalf("got %d members", len(headers))
+	}
+
+	// the tarball layout
+	var buf bytes.Buffer
+	dw := NewWriter(&buf)
+	dw.ModTime = time.Unix(1405990895, 0)
+	dw.Layout = LayoutTar
+	dw.WriteControlFile("control", 0644, []byte(testControl))
+	for _, f := range testFiles {
+		hdr := f.hdr
+		hdr.Size = int64(len(f.body))
+		dw.WriteHeader(&hdr)
+		io.WriteString(dw, f.body)
+	}
+	if err := dw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	layouts = append(layouts, buf.Bytes())
+
+	for i, ipk := range layouts {
+		dr, err := NewReader(stream{bytes.NewReader(ipk)})
+		if err != nil {
+			t.Fatalf("layout %d: NewReader: %v", i, err)
+		}
+		if wantLayout := map[bool]Layout{false: LayoutAr, true: LayoutTar}[i == 2]; dr.Layout() != wantLayout {
+			t.Errorf("layout %d: Layout = %v, want %v", i, dr.Layout(), wantLayout)
+		}
+		var names []string
+		for {
+			hdr, err := dr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("layout %d: Next: %v", i, err)
+			}
+			names = append(names, hdr.Name)
+			if hdr.Mode != 100644 || hdr.FileInfo().Mode() != 0644 {
+				t.Errorf("layout %d: %s mode %d (%v), want 100644", i, hdr.Name, hdr.Mode, hdr.FileInfo().Mode())
+			}
+		}
+		if got := strings.Join(names, " "); got != "debian-binary control.tar.gz data.tar.gz" {
+			t.Errorf("layout %d: members %s", i, got)
+		}
+		if control, err := ReadControl(bytes.NewReader(ipk)); err != nil || control.String() != testControl {
+			t.Errorf("layout %d: ReadControl = %q, %v", i, control, err)
+		}
+		var got bytes.Buffer
+		if err := ListContents(&got, bytes.NewReader(ipk)); err != nil {
+			t.Fatalf("layout %d: ListContents: %v", i, err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("layout %d: contents\n%s\nwant\n%s", i, got.String(), want.String())
+		}
+	}
+
+	dw = NewWriter(ioutil.Discard)
+	dw.Layout = LayoutOld
+	if err := dw.Close(); !errors.Is(err, ErrUnsupportedLayout) {
+		t.Errorf("Close with LayoutOld = %v, want %v", err, ErrUnsupportedLayout)
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Relation operators, as used in version constraints.
+const (
+	OpEarlier        = "<<"
+	OpEarlierOrEqual = "<="
+	OpEqual          = "="
+	OpLaterOrEqual   = ">="
+	OpLater          = ">>"
+)
+
+// A Relation is a single package in a relationship field such as Depends,
+// with any version constraint, architecture qualifier, architecture restriction and build profiles:
+//
+//	name[:archqual] [(op version)] [[arch ...]] [<profile ...> ...]
+type Relation struct {
+	Name          string
+	ArchQualifier string     // e.g. "any" in "python3:any"
+	Op            string     // version constraint operator, one of the Op constants, or empty for none
+	Version       Version    // version constraint, if Op is set
+	Archs         []string   // architecture restriction, e.g. ["amd64", "i386"] or ["!hurd-i386"]
+	Profiles      [][]string // build profile restrictions: the relation applies if every term of any one list holds
+}
+
+// Relations is a parsed relationship field: each element is a list of alternatives, any one of which
+// satisfies it, and every element must be satisfied.
+type Relations [][]Relation
+
+// ParseRelations parses a relationship field, such as Depends, Pre-Depends, Recommends,
+// Conflicts, Breaks, Provides or Build-Depends.
+func ParseRelations(field string) (Relations, error) {
+	var rels Relations
+	for _, group := range strings.Split(field, ",") {
+		if strings.TrimSpace(group) == "" {
+			continue
+		}
+		var alts []Relation
+		for _, alt := range strings.Split(group, "|") {
+			r, err := ParseRelation(alt)
+			if err != nil {
+				return nil, err
+			}
+			alts = append(alts, r)
+		}
+		rels = append(rels, alts)
+	}
+	return rels, nil
+}
+
+// ParseRelation parses a single relation, without alternatives.
+func ParseRelation(s string) (Relation, error) {
+	var r Relation
+	p := relationParser{s: s}
+	p.skipSpace()
+	r.Name = p.until(" \t\n(:[<")
+	if r.Name == "" || !isPackageName(r.Name) {
+		return r, p.errorf("bad package name %q", r.Name)
+	}
+	if p.consume(':') {
+		r.ArchQualifier = p.until(" \t\n([<")
+		if r.ArchQualifier == "" {
+			return r, p.errorf("empty architecture qualifier")
+		}
+	}
+	p.skipSpace()
+	if p.consume('(') {
+		constraint, ok := p.upTo(')')
+		if !ok {
+			return r, p.errorf("unterminated version constraint")
+		}
+		constraint = strings.TrimSpace(constraint)
+		i := strings.IndexFunc(constraint, func(c rune) bool { return !strings.ContainsRune("<>=", c) })
+		if i < 0 {
+			i = len(constraint)
+		}
+		switch op := constraint[:i]; op {
+		case OpEarlier, OpEarlierOrEqual, OpEqual, OpLaterOrEqual, OpLater:
+			r.Op = op
+		case "<":
+			r.Op = OpEarlierOrEqual // obsolete, with the meaning dpkg gives it
+		case ">":
+			r.Op = OpLaterOrEqual
+		default:
+			return r, p.errorf("bad version operator %q", op)
+		}
+		v, err := ParseVersion(constraint[i:])
+		if err != nil {
+			return r, p.errorf("%v", err)
+		}
+		r.Version = v
+	}
+	p.skipSpace()
+	if p.consume('[') {
+		list, ok := p.upTo(']')
+		if !ok {
+			return r, p.errorf("unterminated architecture restriction")
+		}
+		r.Archs = strings.Fields(list)
+		negated := 0
+		for _, a := range r.Archs {
+			if strings.HasPrefix(a, "!") {
+				negated++
+			}
+		}
+		if len(r.Archs) == 0 || negated != 0 && negated != len(r.Archs) {
+			return r, p.errorf("architecture restriction %q must be all negated or none", list)
+		}
+	}
+	p.skipSpace()
+	for p.consume('<') {
+		list, ok := p.upTo('>')
+		if !ok {
+			return r, p.errorf("unterminated build profile restriction")
+		}
+		terms := strings.Fields(list)
+		if len(terms) == 0 {
+			return r, p.errorf("empty build profile restriction")
+		}
+		r.Profiles = append(r.Profiles, terms)
+		p.skipSpace()
+	}
+	if p.i < len(p.s) {
+		return r, p.errorf("unexpected %q", p.s[p.i:])
+	}
+	return r, nil
+}
+
+// isPackageName reports whether name is a valid package name:
+// lower case letters, digits, '+', '-' and '.', starting with an alphanumeric.
+func isPackageName(name string) bool {
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case i > 0 && strings.ContainsRune("+-.", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Satisfied reports whether version v of the named package meets the relation's version constraint.
+// A relation without a constraint is satisfied by any version.
+func (r Relation) Satisfied(v Version) bool {
+	c := Compare(v, r.Version)
+	switch r.Op {
+	case OpEarlier:
+		return c < 0
+	case OpEarlierOrEqual:
+		return c <= 0
+	case OpEqual:
+		return c == 0
+	case OpLaterOrEqual:
+		return c >= 0
+	case OpLater:
+		return c > 0
+	}
+	return true
+}
+
+// String formats the relation as it would appear in a control file.
+func (r Relation) String() string {
+	s := r.Name
+	if r.ArchQualifier != "" {
+		s += ":" + r.ArchQualifier
+	}
+	if r.Op != "" {
+		s += " (" + r.Op + " " + r.Version.String() + ")"
+	}
+	if len(r.Archs) > 0 {
+		s += " [" + strings.Join(r.Archs, " ") + "]"
+	}
+	for _, terms := range r.Profiles {
+		s += " <" + strings.Join(terms, " ") + ">"
+	}
+	return s
+}
+
+// String formats the relations as a relationship field value.
+func (rels Relations) String() string {
+	groups := make([]string, len(rels))
+	for i, alts := range rels {
+		names := make([]string, len(alts))
+		for j, r := range alts {
+			names[j] = r.String()
+		}
+		groups[i] = strings.Join(names, " | ")
+	}
+	return strings.Join(groups, ", ")
+}
+
+type relationParser struct {
+	s string
+	i int
+}
+
+func (p *relationParser) skipSpace() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+func (p *relationParser) consume(c byte) bool {
+	if p.i < len(p.s) && p.s[p.i] == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// until returns the text up to the next of the given delimiters, or the end.
+func (p *relationParser) until(delims string) string {
+	start := p.i
+	for p.i < len(p.s) && strings.IndexByte(delims, p.s[p.i]) < 0 {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// upTo returns the text up to the closing delimiter c, consuming c.
+func (p *relationParser) upTo(c byte) (string, bool) {
+	i := strings.IndexByte(p.s[p.i:], c)
+	if i < 0 {
+		return "", false
+	}
+	text := p.s[p.i : p.i+i]
+	p.i += i + 1
+	return text, true
+}
+
+func (p *relationParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("deb: relation %q: %s", strings.TrimSpace(p.s), fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,63 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRelations(t *testing.T) {
+	in := "libc6 (>= 2.14), python3:any (<< 3.12~) | python3-minimal, , foo [!hurd-i386 !kfreebsd-any] <!nocheck> <cross stage1>,\n bar (< 1.0)"
+	rels, err := ParseRelations(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Relations{
+		{{Name: "libc6", Op: OpLaterOrEqual, Version: Version{0, "2.14", ""}}},
+		{
+			{Name: "python3", ArchQualifier: "any", Op: OpEarlier, Version: Version{0, "3.12~", ""}},
+			{Name: "python3-minimal"},
+		},
+		{{Name: "foo", Archs: []string{"!hurd-i386", "!kfreebsd-any"}, Profiles: [][]string{{"!nocheck"}, {"cross", "stage1"}}}},
+		{{Name: "bar", Op: OpEarlierOrEqual, Version: Version{0, "1.0", ""}}},
+	}
+	if !reflect.DeepEqual(rels, want) {
+		t.Errorf("got %+v, want %+v", rels, want)
+	}
+	if got, want := rels.String(), "libc6 (>= 2.14), python3:any (<< 3.12~) | python3-minimal, foo [!hurd-i386 !kfreebsd-any] <!nocheck> <cross stage1>, bar (<= 1.0)"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+
+	for _, bad := range []string{"Libc6", "libc6 (>= 2.14", "libc6 (~ 1.0)", "libc6 (>= )", "foo [amd64 !i386]", "foo <>", "foo bar", "foo |"} {
+		if _, err := ParseRelations(bad); err == nil {
+			t.Errorf("ParseRelations(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestSatisfied(t *testing.T) {
+	rels, err := ParseRelations("a (<< 1.0), b (<= 1.0), c (= 1.0), d (>= 1.0), e (>> 1.0), f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][3]bool{ // satisfied by 1.0~, 1.0, 1.0-1
+		"a": {true, false, false},
+		"b": {true, true, false},
+		"c": {false, true, false},
+		"d": {false, true, true},
+		"e": {false, false, true},
+		"f": {true, true, true},
+	}
+	for _, alts := range rels {
+		r := alts[0]
+		for i, s := range []string{"1.0~", "1.0", "1.0-1"} {
+			v, _ := ParseVersion(s)
+			if got := r.Satisfied(v); got != want[r.Name][i] {
+				t.Errorf("%s satisfied by %s = %v", r, s, got)
+			}
+		}
+	}
+}
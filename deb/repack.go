@@ -0,0 +1,119 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/laher/argo/ar"
+)
+
+// An EditFunc changes a package's control file. It may modify control in place.
+type EditFunc func(control *Paragraph) error
+
+// Repack copies the package read from r to w, with its control file changed by edit.
+// The control tarball is rebuilt with the same compression, keeping its other files and their tar headers;
+// every other member, including debian-binary and the data tarball, is copied byte for byte.
+// All members keep their original ar headers, other than the control tarball's size.
+// Repack returns ErrUnsupportedCompression if no Compressor is registered for the control tarball's compression,
+// ErrNoControl if the control tarball has no control file, and ErrNotAr for a package which is not an ar archive.
+func Repack(w io.Writer, r io.Reader, edit EditFunc) error {
+	dr, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	if dr.Layout() != LayoutAr {
+		return ErrNotAr
+	}
+	aw := ar.NewWriter(w)
+	found := false
+	for {
+		hdr, err := dr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if !IsControl(hdr.Name) || found {
+			if err := aw.CopyFrom(dr.ar); err != nil {
+				return err
+			}
+			continue
+		}
+		found = true
+		var control bytes.Buffer
+		if err := rebuildControl(&control, dr, hdr.Name, edit); err != nil {
+			return err
+		}
+		if err := aw.CopyHeaderFrom(dr.ar, int64(control.Len())); err != nil {
+			return err
+		}
+		if _, err := control.WriteTo(aw); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return ErrNoControl
+	}
+	return aw.Close()
+}
+
+// rebuildControl reads the current member of dr, a control tarball, and writes it to w
+// with the control file changed by edit.
+func rebuildControl(w io.Writer, dr *Reader, name string, edit EditFunc) error {
+	c, _ := CompressionOf(name)
+	z, err := NewCompressor(c, w)
+	if err != nil {
+		return err
+	}
+	tr, err := dr.Tar()
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(z)
+	found := false
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		body, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if controlPath(th.Name) == ControlName && th.Typeflag == tar.TypeReg && !found {
+			found = true
+			control, err := ParseParagraph(bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			if err := edit(&control); err != nil {
+				return err
+			}
+			body = []byte(control.String())
+			th.Size = int64(len(body))
+		}
+		if err := tw.WriteHeader(th); err != nil {
+			return err
+		}
+		if _, err := tw.Write(body); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return ErrNoControl
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return z.Close()
+}
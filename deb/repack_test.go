@@ -0,0 +1,95 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/laher/argo/ar"
+)
+
+// readMembers returns the raw header and data of each member of an ar archive.
+func readMembers(t *testing.T, b []byte) (headers, bodies []string) {
+	r, err := ar.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			return headers, bodies
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers = append(headers, string(r.RawHeader()))
+		bodies = append(bodies, string(body))
+	}
+}
+
+func TestRepack(t *testing.T) {
+	orig := buildPackage(t, testFiles, map[string]string{"postinst": "#!/bin/sh\n"})
+	var out bytes.Buffer
+	err := Repack(&out, bytes.NewReader(orig), func(control *Paragraph) error {
+		control.Set("Version", "1.0-1+acme1")
+		control.Set("Maintainer", "Acme Ops <ops@example.com>")
+		control.Set("Depends", "acme-base (>= 2)")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Repack: %v", err)
+	}
+	control, err := ReadControl(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Package: hello\nVersion: 1.0-1+acme1\nArchitecture: all\nMaintainer: Acme Ops <ops@example.com>\nDepends: acme-base (>= 2)\nDescription: greeting\n greets the world\n"
+	if control.String() != want {
+		t.Errorf("control =\n%s\nwant\n%s", control, want)
+	}
+
+	origHeaders, origBodies := readMembers(t, orig)
+	headers, bodies := readMembers(t, out.Bytes())
+	if len(headers) != 3 || len(origHeaders) != 3 {
+		t.Fatalf("got %d members, want 3", len(headers))
+	}
+	for _, i := range []int{0, 2} {
+		if headers[i] != origHeaders[i] || bodies[i] != origBodies[i] {
+			t.Errorf("member %d changed", i)
+		}
+	}
+	if headers[1][:48] != origHeaders[1][:48] {
+		t.Errorf("control header = %q, want it to start %q", headers[1], origHeaders[1][:48])
+	}
+
+	dr, err := NewReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		hdr, err := dr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if IsControl(hdr.Name) {
+			break
+		}
+	}
+	tr, err := dr.Tar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readTarFiles(t, tr)
+	if files["./postinst"] != "#!/bin/sh\n" || files["./md5sums"] == "" {
+		t.Errorf("control files = %q", files)
+	}
+}
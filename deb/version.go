@@ -0,0 +1,175 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Version is a Debian package version, [epoch:]upstream_version[-debian_revision].
+type Version struct {
+	Epoch    int
+	Upstream string
+	Revision string // empty for a native package
+}
+
+// ParseVersion parses a version string as dpkg does.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	orig := s
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return v, fmt.Errorf("deb: empty version")
+	}
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		epoch, err := strconv.Atoi(s[:i])
+		if err != nil || epoch < 0 || s[:i] == "" || strings.IndexAny(s[:i], "+-") >= 0 {
+			return v, fmt.Errorf("deb: version %q has a bad epoch", orig)
+		}
+		v.Epoch = epoch
+		s = s[i+1:]
+	}
+	if i := strings.LastIndexByte(s, '-'); i >= 0 {
+		v.Revision = s[i+1:]
+		s = s[:i]
+		if v.Revision == "" {
+			return v, fmt.Errorf("deb: version %q has an empty revision", orig)
+		}
+	}
+	v.Upstream = s
+	switch {
+	case v.Upstream == "":
+		return v, fmt.Errorf("deb: version %q has an empty upstream version", orig)
+	case v.Upstream[0] < '0' || v.Upstream[0] > '9':
+		return v, fmt.Errorf("deb: version %q does not start with a digit", orig)
+	}
+	for _, c := range v.Upstream {
+		if !isVersionChar(c) && c != '-' && c != ':' {
+			return v, fmt.Errorf("deb: version %q has an invalid character %q", orig, c)
+		}
+	}
+	for _, c := range v.Revision {
+		if !isVersionChar(c) {
+			return v, fmt.Errorf("deb: version %q has an invalid character %q in its revision", orig, c)
+		}
+	}
+	return v, nil
+}
+
+func isVersionChar(c rune) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || strings.ContainsRune(".+~", c)
+}
+
+// String formats the version, leaving out a zero epoch and an empty revision.
+func (v Version) String() string {
+	s := v.Upstream
+	if v.Epoch > 0 {
+		s = strconv.Itoa(v.Epoch) + ":" + s
+	}
+	if v.Revision != "" {
+		s += "-" + v.Revision
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 as a is older than, the same as, or newer than b, by dpkg's rules:
+// epochs compare numerically, then upstream versions and revisions compare by alternating
+// runs of non-digits (where '~' sorts before anything, even the end of the string, and letters
+// before other characters) and runs of digits (numerically).
+func Compare(a, b Version) int {
+	switch {
+	case a.Epoch < b.Epoch:
+		return -1
+	case a.Epoch > b.Epoch:
+		return 1
+	}
+	if c := compareFragment(a.Upstream, b.Upstream); c != 0 {
+		return c
+	}
+	return compareFragment(a.Revision, b.Revision)
+}
+
+// CompareVersions parses and compares two version strings, as 'dpkg --compare-versions' does.
+func CompareVersions(a, b string) (int, error) {
+	va, err := ParseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := ParseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	return Compare(va, vb), nil
+}
+
+// order weights a character for comparison, with 0 standing for the end of the string.
+func order(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	c := s[i]
+	switch {
+	case c >= '0' && c <= '9':
+		return 0
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return int(c)
+	case c == '~':
+		return -1
+	}
+	return int(c) + 256
+}
+
+func isDigit(s string, i int) bool {
+	return i < len(s) && s[i] >= '0' && s[i] <= '9'
+}
+
+// compareFragment compares upstream versions or revisions, as dpkg's verrevcmp does.
+func compareFragment(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isDigit(a, i) || j < len(b) && !isDigit(b, j) {
+			if oa, ob := order(a, i), order(b, j); oa != ob {
+				return sign(oa - ob)
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		firstDiff := 0
+		for isDigit(a, i) && isDigit(b, j) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		switch {
+		case isDigit(a, i):
+			return 1
+		case isDigit(b, j):
+			return -1
+		case firstDiff != 0:
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want Version
+	}{
+		{"1.0", Version{0, "1.0", ""}},
+		{"1.0-1", Version{0, "1.0", "1"}},
+		{"2:1.0-1ubuntu1", Version{2, "1.0", "1ubuntu1"}},
+		{"1:2.3-rc1-4", Version{1, "2.3-rc1", "4"}},
+		{"1:2:3", Version{1, "2:3", ""}},
+		{"1.0~beta+dfsg", Version{0, "1.0~beta+dfsg", ""}},
+	} {
+		v, err := ParseVersion(test.in)
+		if err != nil {
+			t.Errorf("ParseVersion(%q): %v", test.in, err)
+			continue
+		}
+		if v != test.want {
+			t.Errorf("ParseVersion(%q) = %+v, want %+v", test.in, v, test.want)
+		}
+		if v.String() != test.in {
+			t.Errorf("String = %q, want %q", v.String(), test.in)
+		}
+	}
+	for _, bad := range []string{"", "a1.0", "x:1.0", "-1:1.0", "1.0-", "1.0_2", "1:", "1.0-a:b"} {
+		if _, err := ParseVersion(bad); err == nil {
+			t.Errorf("ParseVersion(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// Each version is older than the next, by 'dpkg --compare-versions'.
+	ordered := []string{
+		"0.9",
+		"1.0~~",
+		"1.0~~a",
+		"1.0~",
+		"1.0",
+		"1.0-1~bpo1",
+		"1.0-1",
+		"1.0-1ubuntu1",
+		"1.0a",
+		"1.0+",
+		"1.0.1",
+		"1.2",
+		"1.10",
+		"1.010a",
+		"1:0.1",
+		"2:0.0",
+	}
+	for i := 0; i+1 < len(ordered); i++ {
+		a, b := ordered[i], ordered[i+1]
+		if c, err := CompareVersions(a, b); err != nil || c != -1 {
+			t.Errorf("CompareVersions(%q, %q) = %d, %v, want -1", a, b, c, err)
+		}
+		if c, _ := CompareVersions(b, a); c != 1 {
+			t.Errorf("CompareVersions(%q, %q) = %d, want 1", b, a, c)
+		}
+	}
+	for _, pair := range [][2]string{{"1.0", "1.00"}, {"1.0", "1.0-0"}, {"0:1.0", "1.0"}, {"1.01-01", "1.1-1"}} {
+		if c, err := CompareVersions(pair[0], pair[1]); err != nil || c != 0 {
+			t.Errorf("CompareVersions(%q, %q) = %d, %v, want 0", pair[0], pair[1], c, err)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/laher/argo/ar"
+)
+
+var (
+	// ErrWriteAfterClose shows that a write was attempted after the package has been closed
+	ErrWriteAfterClose = errors.New("deb: write after close")
+	// ErrUnsupportedLayout shows that a package was to be written in a layout which cannot be written
+	ErrUnsupportedLayout = errors.New("deb: unsupported layout for writing")
+)
+
+// A Writer builds a Debian package.
+// Call WriteControlFile to add files such as 'control' and maintainer scripts to the control tarball.
+// Call WriteHeader to begin a new file in the data tarball, and then call Write to supply that file's data.
+// The package is written to the underlying writer on Close, along with a generated md5sums file
+// covering every regular file in the data tarball (other than conffiles).
+// If the caller supplies its own md5sums control file, no md5sums file is generated.
+type Writer struct {
+	w           io.Writer
+	ModTime     time.Time   // modification time of the ar members and control files. Defaults to the time of Close.
+	Compression Compression // compression of the control and data tarballs. Defaults to gzip.
+	Layout      Layout      // how the members are packed together. Defaults to LayoutAr; LayoutTar builds a tarball-style ipk.
+
+	control   []controlFile
+	data      bytes.Buffer
+	dataZ     io.WriteCloser
+	dataTar   *tar.Writer
+	sums      []fileSum
+	cur       hash.Hash // checksum of the current data file, if it is a regular file
+	curName   string
+	conffiles []string
+	closed    bool
+	err       error
+}
+
+type controlFile struct {
+	name string
+	mode int64
+	body []byte
+}
+
+type fileSum struct {
+	name string
+	sum  string
+}
+
+// NewWriter creates a new Writer writing a package to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, Compression: CompressionGzip}
+}
+
+// WriteControlFile adds a file to the control tarball.
+func (dw *Writer) WriteControlFile(name string, mode int64, body []byte) error {
+	if dw.closed {
+		return ErrWriteAfterClose
+	}
+	name = cleanPath(name)
+	if name == ConffilesName {
+		dw.conffiles = parseConffiles(body)
+	}
+	dw.control = append(dw.control, controlFile{name, mode, body})
+	return nil
+}
+
+// WriteHeader writes hdr to the data tarball and prepares to accept the file's contents.
+func (dw *Writer) WriteHeader(hdr *tar.Header) error {
+	if dw.closed {
+		return ErrWriteAfterClose
+	}
+	if dw.err != nil {
+		return dw.err
+	}
+	if dw.err = dw.initData(); dw.err != nil {
+		return dw.err
+	}
+	dw.finishSum()
+	if dw.err = dw.dataTar.WriteHeader(hdr); dw.err != nil {
+		return dw.err
+	}
+	switch hdr.Typeflag {
+	case tar.TypeReg:
+		dw.cur = md5.New()
+		dw.curName = cleanPath(hdr.Name)
+	case tar.TypeLink:
+		// hard links are regular files once unpacked, so dpkg lists them too
+		target := cleanPath(hdr.Linkname)
+		for _, s := range dw.sums {
+			if s.name == target {
+				dw.sums = append(dw.sums, fileSum{cleanPath(hdr.Name), s.sum})
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Write writes to the current file in the data tarball.
+func (dw *Writer) Write(b []byte) (int, error) {
+	if dw.closed {
+		return 0, ErrWriteAfterClose
+	}
+	if dw.dataTar == nil {
+		return 0, tar.ErrWriteTooLong // no header written yet
+	}
+	n, err := dw.dataTar.Write(b)
+	if dw.cur != nil {
+		dw.cur.Write(b[:n])
+	}
+	if err != nil {
+		dw.err = err
+	}
+	return n, err
+}
+
+// initData starts the data tarball, once the compression is known.
+func (dw *Writer) initData() error {
+	if dw.dataTar != nil {
+		return nil
+	}
+	z, err := NewCompressor(dw.Compression, &dw.data)
+	if err != nil {
+		return err
+	}
+	dw.dataZ = z
+	dw.dataTar = tar.NewWriter(z)
+	return nil
+}
+
+func (dw *Writer) finishSum() {
+	if dw.cur != nil {
+		dw.sums = append(dw.sums, fileSum{dw.curName, fmt.Sprintf("%x", dw.cur.Sum(nil))})
+		dw.cur = nil
+	}
+}
+
+// Close finishes the data tarball, builds the control tarball and writes the package.
+func (dw *Writer) Close() error {
+	if dw.closed {
+		return dw.err
+	}
+	dw.closed = true
+	if dw.err != nil {
+		return dw.err
+	}
+	dw.finishSum()
+	if dw.ModTime.IsZero() {
+		dw.ModTime = time.Now()
+	}
+	if dw.err = dw.initData(); dw.err != nil {
+		return dw.err
+	}
+	if dw.err = dw.dataTar.Close(); dw.err != nil {
+		return dw.err
+	}
+	if dw.err = dw.dataZ.Close(); dw.err != nil {
+		return dw.err
+	}
+	var control bytes.Buffer
+	if dw.err = dw.writeControl(&control); dw.err != nil {
+		return dw.err
+	}
+
+	suffix := dw.Compression.Suffix()
+	members := []member{
+		{BinaryName, []byte(BinaryVersion)},
+		{ControlPrefix + suffix, control.Bytes()},
+		{DataPrefix + suffix, dw.data.Bytes()},
+	}
+	switch dw.Layout {
+	case LayoutAr:
+		dw.err = writeArMembers(dw.w, members, dw.ModTime)
+	case LayoutTar:
+		dw.err = writeTarMembers(dw.w, members, dw.ModTime)
+	default:
+		dw.err = fmt.Errorf("%w: %s", ErrUnsupportedLayout, dw.Layout)
+	}
+	return dw.err
+}
+
+// A member is an ar member (or, for LayoutTar, a tarball entry) of a package being written.
+type member struct {
+	name string
+	body []byte
+}
+
+// writeArMembers writes members to w as an ar archive.
+func writeArMembers(w io.Writer, members []member, modTime time.Time) error {
+	aw := ar.NewWriter(w)
+	for _, m := range members {
+		hdr := &ar.Header{
+			Name:    m.name,
+			ModTime: modTime,
+			Mode:    644,
+			Size:    int64(len(m.body)),
+		}
+		if err := aw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := aw.Write(m.body); err != nil {
+			return err
+		}
+	}
+	return aw.Close()
+}
+
+// writeTarMembers writes members to w as a gzipped tarball, with "./"-prefixed names as opkg-build writes them.
+func writeTarMembers(w io.Writer, members []member, modTime time.Time) error {
+	z := gzip.NewWriter(w)
+	tw := tar.NewWriter(z)
+	for _, m := range members {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     "./" + m.name,
+			Mode:     0644,
+			Size:     int64(len(m.body)),
+			ModTime:  modTime,
+			Typeflag: tar.TypeReg,
+			Uname:    "root",
+			Gname:    "root",
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := tw.Write(m.body); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+// writeControl writes the compressed control tarball, including a generated md5sums file.
+func (dw *Writer) writeControl(w io.Writer) error {
+	files := dw.control
+	if !dw.hasControlFile(Md5sumsName) {
+		files = append(files, controlFile{Md5sumsName, 0644, dw.md5sums()})
+	}
+	z, err := NewCompressor(dw.Compression, w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(z)
+	err = tw.WriteHeader(&tar.Header{
+		Name:     "./",
+		Mode:     0755,
+		ModTime:  dw.ModTime,
+		Typeflag: tar.TypeDir,
+		Uname:    "root",
+		Gname:    "root",
+	})
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     "./" + f.name,
+			Mode:     f.mode,
+			Size:     int64(len(f.body)),
+			ModTime:  dw.ModTime,
+			Typeflag: tar.TypeReg,
+			Uname:    "root",
+			Gname:    "root",
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.body); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+func (dw *Writer) hasControlFile(name string) bool {
+	for _, f := range dw.control {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// md5sums renders the md5sums file for the data written so far, leaving out conffiles.
+func (dw *Writer) md5sums() []byte {
+	conffiles := make(map[string]bool, len(dw.conffiles))
+	for _, name := range dw.conffiles {
+		conffiles[name] = true
+	}
+	var b bytes.Buffer
+	for _, s := range dw.sums {
+		if !conffiles[s.name] {
+			fmt.Fprintf(&b, "%s  %s\n", s.sum, s.name)
+		}
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,143 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+type tarEntry struct {
+	hdr  tar.Header
+	body string
+}
+
+var testControl = "Package: hello\nVersion: 1.0-1\nArchitecture: all\nMaintainer: Am Laher <am@laher.net.nz>\nDescription: greeting\n greets the world\n"
+
+var testTime = time.Unix(1405990895, 0)
+
+var testFiles = []tarEntry{
+	{tar.Header{Name: "./usr/bin/hello", Typeflag: tar.TypeReg, Mode: 0644, ModTime: testTime}, "#!/bin/sh\necho hello\n"},
+	{tar.Header{Name: "./etc/hello.conf", Typeflag: tar.TypeReg, Mode: 0644, ModTime: testTime}, "greeting=hello\n"},
+	{tar.Header{Name: "./usr/share/doc/hello/README", Typeflag: tar.TypeReg, Mode: 0644, ModTime: testTime}, "Say hello"},
+	{tar.Header{Name: "./usr/bin/hi", Typeflag: tar.TypeLink, Linkname: "./usr/bin/hello", Mode: 0644, ModTime: testTime}, ""},
+}
+
+// buildPackage writes a package whose data tarball holds entries, exactly as given but for their sizes,
+// with any extra control files given. Maintainer scripts among them are made executable.
+func buildPackage(t *testing.T, entries []tarEntry, control map[string]string) []byte {
+	var buf bytes.Buffer
+	dw := NewWriter(&buf)
+	dw.ModTime = testTime
+	if err := dw.WriteControlFile("control", 0644, []byte(testControl)); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range control {
+		mode := int64(0644)
+		switch name {
+		case "preinst", "postinst", "prerm", "postrm", "config":
+			mode = 0755
+		}
+		if err := dw.WriteControlFile(name, mode, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range entries {
+		hdr := e.hdr
+		hdr.Size = int64(len(e.body))
+		if err := dw.WriteHeader(&hdr); err != nil {
+			t.Fatalf("WriteHeader(%s): %v", hdr.Name, err)
+		}
+		if _, err := io.WriteString(dw, e.body); err != nil {
+			t.Fatalf("Write(%s): %v", hdr.Name, err)
+		}
+	}
+	if err := dw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriter(t *testing.T) {
+	pkg := buildPackage(t, testFiles, map[string]string{"conffiles": "/etc/hello.conf\n"})
+	dr, err := NewReader(bytes.NewReader(pkg))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	wantMembers := []string{"debian-binary", "control.tar.gz", "data.tar.gz"}
+	for i, want := range wantMembers {
+		hdr, err := dr.Next()
+		if err != nil {
+			t.Fatalf("member %d: %v", i, err)
+		}
+		if hdr.Name != want {
+			t.Errorf("member %d: Name = %q, want %q", i, hdr.Name, want)
+		}
+		switch {
+		case hdr.Name == BinaryName:
+			b, err := ioutil.ReadAll(dr)
+			if err != nil || string(b) != BinaryVersion {
+				t.Errorf("debian-binary = %q, %v", b, err)
+			}
+		case IsControl(hdr.Name):
+			tr, err := dr.Tar()
+			if err != nil {
+				t.Fatalf("Tar: %v", err)
+			}
+			files := readTarFiles(t, tr)
+			if files["./control"] != testControl {
+				t.Errorf("control = %q, want %q", files["./control"], testControl)
+			}
+			sums, err := ParseMd5sums(strings.NewReader(files["./md5sums"]))
+			if err != nil {
+				t.Fatalf("ParseMd5sums: %v", err)
+			}
+			for _, name := range []string{"usr/bin/hello", "usr/bin/hi", "usr/share/doc/hello/README"} {
+				if _, ok := sums[name]; !ok {
+					t.Errorf("md5sums missing %s", name)
+				}
+			}
+			if _, ok := sums["etc/hello.conf"]; ok {
+				t.Errorf("md5sums should not list conffile etc/hello.conf")
+			}
+		}
+	}
+	if _, err := dr.Next(); err != io.EOF {
+		t.Errorf("expected EOF after data member, got %v", err)
+	}
+}
+
+func TestWriterAfterClose(t *testing.T) {
+	dw := NewWriter(ioutil.Discard)
+	if err := dw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := dw.WriteHeader(&tar.Header{Name: "x"}); err != ErrWriteAfterClose {
+		t.Errorf("WriteHeader after Close = %v, want %v", err, ErrWriteAfterClose)
+	}
+}
+
+func readTarFiles(t *testing.T, tr *tar.Reader) map[string]string {
+	files := make(map[string]string)
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("tar Next: %v", err)
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("tar Read: %v", err)
+		}
+		files[th.Name] = string(b)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package goarchive inspects the package archives (.a files) produced by the Go toolchain.
+// A Go package archive is an ar archive holding a '__.PKGDEF' member with the package's export data,
+// one or more Go object files (usually '_go_.o'), and, for cgo packages, native object files.
+// Each Go member begins with a 'go object' line naming the target and toolchain version,
+// followed by a 'build id' line.
+//
+// References:
+//
+//	https://pkg.go.dev/cmd/pack
+//	https://go.dev/src/cmd/internal/archive/archive.go
+package goarchive
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/laher/argo/ar"
+)
+
+// PkgDefName is the name of the member holding a package's export data.
+const PkgDefName = "__.PKGDEF"
+
+// goObjectPrefix starts the first line of every Go member.
+const goObjectPrefix = "go object "
+
+// Kind classifies a member of a Go package archive.
+type Kind int
+
+const (
+	// KindUnknown is a member which is neither Go nor a recognised native object
+	KindUnknown Kind = iota
+	// KindPkgDef is the __.PKGDEF member, holding export data
+	KindPkgDef
+	// KindGoObject is an object file produced by the Go compiler
+	KindGoObject
+	// KindNativeObject is a native object file, such as a cgo-compiled ELF object
+	KindNativeObject
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindPkgDef:
+		return "pkgdef"
+	case KindGoObject:
+		return "go object"
+	case KindNativeObject:
+		return "native object"
+	}
+	return "unknown"
+}
+
+// ErrNoExportData shows that a __.PKGDEF member has no recognisable export data section
+var ErrNoExportData = errors.New("goarchive: no export data")
+
+// A Member describes one member of a Go package archive.
+type Member struct {
+	Name string
+	Size int64
+	Kind Kind
+
+	// Fields of the 'go object' line, for Go members.
+	Header    string   // the whole 'go object' line, without its newline
+	GOOS      string   // target operating system
+	GOARCH    string   // target architecture
+	GoVersion string   // toolchain version, e.g. "go1.21.3"
+	Extra     []string // remaining fields, such as "GOAMD64=v1" and "X:" experiments
+
+	// BuildID is the unquoted build ID, for Go members which record one.
+	BuildID string
+
+	// ExportStart and ExportEnd delimit the export data within a __.PKGDEF member's data,
+	// i.e. the bytes between the "$$B" marker line and the closing "\n$$\n".
+	// Both are -1 for members without export data.
+	ExportStart int64
+	ExportEnd   int64
+	// ExportFormat is 'B' for binary export data, or 0 for the old textual format.
+	ExportFormat byte
+
+	// ObjectFormat names the format of native objects: "elf", "macho", "pe" or "xcoff".
+	ObjectFormat string
+}
+
+// IsCgo reports whether the member is a native object, as produced for cgo packages.
+func (m *Member) IsCgo() bool {
+	return m.Kind == KindNativeObject
+}
+
+// Inspect reads a Go package archive sequentially, describing each member.
+func Inspect(r io.Reader) ([]*Member, error) {
+	arr, err := ar.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var members []*Member
+	for {
+		hdr, err := arr.Next()
+		if err == io.EOF {
+			return members, nil
+		}
+		if err != nil {
+			return members, err
+		}
+		m, err := InspectMember(hdr.Name, hdr.Size, arr)
+		if err != nil {
+			return members, err
+		}
+		members = append(members, m)
+	}
+}
+
+// InspectMember describes a single member, reading its data from r.
+func InspectMember(name string, size int64, r io.Reader) (*Member, error) {
+	m := &Member{Name: name, Size: size, ExportStart: -1, ExportEnd: -1}
+	br := bufio.NewReader(io.LimitReader(r, size))
+	magic, _ := br.Peek(len(goObjectPrefix))
+	if string(magic) != goObjectPrefix {
+		m.ObjectFormat = nativeFormat(magic)
+		if m.ObjectFormat != "" {
+			m.Kind = KindNativeObject
+		}
+		return m, nil
+	}
+	m.Kind = KindGoObject
+	if name == PkgDefName {
+		m.Kind = KindPkgDef
+	}
+
+	var offset int64
+	for {
+		line, err := br.ReadString('\n')
+		offset += int64(len(line))
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, goObjectPrefix):
+			m.parseHeader(line)
+		case strings.HasPrefix(line, "build id "):
+			if id, err := strconv.Unquote(strings.TrimPrefix(line, "build id ")); err == nil {
+				m.BuildID = id
+			}
+		case line == "!":
+			// start of the object proper. Go objects may have cgo directives between "$$" lines before it.
+			return m, nil
+		case m.Kind == KindPkgDef && (line == "$$B" || line == "$$"):
+			if line == "$$B" {
+				m.ExportFormat = 'B'
+			}
+			m.ExportStart = offset
+			end, err := lastIndex(br, offset, []byte("\n$$\n"))
+			if err != nil {
+				return nil, err
+			}
+			if end < 0 {
+				return m, ErrNoExportData
+			}
+			m.ExportEnd = end
+			return m, nil
+		}
+	}
+}
+
+func (m *Member) parseHeader(line string) {
+	m.Header = line
+	fields := strings.Fields(line)
+	if len(fields) > 2 {
+		m.GOOS = fields[2]
+	}
+	if len(fields) > 3 {
+		m.GOARCH = fields[3]
+	}
+	if len(fields) > 4 {
+		m.GoVersion = fields[4]
+	}
+	if len(fields) > 5 {
+		m.Extra = fields[5:]
+	}
+}
+
+// lastIndex streams the rest of r, returning the offset of the last occurrence of sep,
+// given that r starts at offset. It returns -1 if sep does not occur.
+func lastIndex(r io.Reader, offset int64, sep []byte) (int64, error) {
+	found := int64(-1)
+	buf := make([]byte, 32<<10)
+	var tail []byte // end of the previous chunk, in case sep straddles chunks
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			window := append(tail, buf[:n]...)
+			if i := bytes.LastIndex(window, sep); i >= 0 {
+				found = offset - int64(len(tail)) + int64(i)
+			}
+			keep := len(sep) - 1
+			if keep > len(window) {
+				keep = len(window)
+			}
+			tail = append([]byte(nil), window[len(window)-keep:]...)
+			offset += int64(n)
+		}
+		if err == io.EOF {
+			return found, nil
+		}
+		if err != nil {
+			return -1, err
+		}
+	}
+}
+
+// nativeFormat identifies a native object file by its magic number.
+func nativeFormat(magic []byte) string {
+	switch {
+	case bytes.HasPrefix(magic, []byte("\x7fELF")):
+		return "elf"
+	case bytes.HasPrefix(magic, []byte("\xfe\xed\xfa\xce")), bytes.HasPrefix(magic, []byte("\xfe\xed\xfa\xcf")),
+		bytes.HasPrefix(magic, []byte("\xce\xfa\xed\xfe")), bytes.HasPrefix(magic, []byte("\xcf\xfa\xed\xfe")):
+		return "macho"
+	case bytes.HasPrefix(magic, []byte("\x01\xdf")), bytes.HasPrefix(magic, []byte("\x01\xf7")):
+		return "xcoff"
+	case len(magic) >= 2 && isCOFFMachine(uint16(magic[0])|uint16(magic[1])<<8):
+		return "pe"
+	}
+	return ""
+}
+
+// isCOFFMachine reports whether m is the machine field of a COFF object, as produced by mingw for cgo on Windows.
+func isCOFFMachine(m uint16) bool {
+	switch m {
+	case 0x14c, 0x8664, 0x1c0, 0x1c4, 0xaa64: // 386, amd64, arm, armnt, arm64
+		return true
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+// Copyright 2014 Am Laher.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package goarchive
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/laher/argo/ar"
+)
+
+const (
+	testHeader  = "go object linux amd64 go1.21.3 GOAMD64=v1 X:regabiwrappers\n"
+	testBuildID = "build id \"abc/def\"\n"
+	testExport  = "u\x04\x00\x00binary\nexport\x00data"
+)
+
+// buildArchive writes an archive in the layout produced by the Go toolchain.
+func buildArchive(t *testing.T) []byte {
+	members := []struct {
+		name, body string
+	}{
+		{PkgDefName, testHeader + testBuildID + "\n\n$$B\n" + testExport + "\n$$\n"},
+		{"_go_.o", testHeader + testBuildID + "\n\n$$\n\n$$\n\n\n$$  // cgo\n[]\n\n$$\n\n\n!\n\x00go120ld"},
+		{"_x001.o", "\x7fELF\x02\x01\x01\x00"},
+		{"notes.txt", "hello"},
+	}
+	var buf bytes.Buffer
+	aw := ar.NewWriter(&buf)
+	for _, m := range members {
+		hdr := &ar.Header{Name: m.name, ModTime: time.Unix(0, 0), Mode: 644, Size: int64(len(m.body))}
+		if err := aw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := aw.Write([]byte(m.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestInspect(t *testing.T) {
+	members, err := Inspect(bytes.NewReader(buildArchive(t)))
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if len(members) != 4 {
+		t.Fatalf("Got %d members, want 4", len(members))
+	}
+	pkgdef := members[0]
+	if pkgdef.Kind != KindPkgDef || pkgdef.GOOS != "linux" || pkgdef.GOARCH != "amd64" || pkgdef.GoVersion != "go1.21.3" {
+		t.Errorf("Unexpected __.PKGDEF: %+v", pkgdef)
+	}
+	if want := []string{"GOAMD64=v1", "X:regabiwrappers"}; !reflect.DeepEqual(pkgdef.Extra, want) {
+		t.Errorf("Extra = %q, want %q", pkgdef.Extra, want)
+	}
+	if pkgdef.BuildID != "abc/def" {
+		t.Errorf("BuildID = %q, want %q", pkgdef.BuildID, "abc/def")
+	}
+	start := int64(len(testHeader + testBuildID + "\n\n$$B\n"))
+	if pkgdef.ExportStart != start || pkgdef.ExportEnd != start+int64(len(testExport)) || pkgdef.ExportFormat != 'B' {
+		t.Errorf("Export data = [%d:%d] %c, want [%d:%d] B", pkgdef.ExportStart, pkgdef.ExportEnd, pkgdef.ExportFormat,
+			start, start+int64(len(testExport)))
+	}
+
+	obj := members[1]
+	if obj.Kind != KindGoObject || obj.BuildID != "abc/def" || obj.ExportStart != -1 || obj.IsCgo() {
+		t.Errorf("Unexpected _go_.o: %+v", obj)
+	}
+	if native := members[2]; native.Kind != KindNativeObject || native.ObjectFormat != "elf" || !native.IsCgo() {
+		t.Errorf("Unexpected _x001.o: %+v", native)
+	}
+	if other := members[3]; other.Kind != KindUnknown {
+		t.Errorf("Unexpected notes.txt: %+v", other)
+	}
+}
+
+func TestLastIndexStraddle(t *testing.T) {
+	// the separator spans two 32KB reads
+	data := bytes.Repeat([]byte("x"), 32<<10-2)
+	data = append(data, "\n$$\nyy"...)
+	got, err := lastIndex(bytes.NewReader(data), 100, []byte("\n$$\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(100 + 32<<10 - 2); got != want {
+		t.Errorf("lastIndex = %d, want %d", got, want)
+	}
+}